@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -10,6 +12,8 @@ import (
 	"time"
 
 	"github.com/sucumbap/mangaroo/internal/api"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/client"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/storage"
 	"github.com/sucumbap/mangaroo/pkg/config"
 	"github.com/sucumbap/mangaroo/pkg/logger"
 )
@@ -24,6 +28,17 @@ func main() {
 		log.Fatal("Failed to load config:", err)
 	}
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			migrate(cfg, os.Args[2:])
+			return
+		case "repair-indices":
+			repairIndices(cfg, os.Args[2:])
+			return
+		}
+	}
+
 	// Initialize Handler with dependencies
 	handler, err := api.NewHandler(cfg)
 	if err != nil {
@@ -39,6 +54,7 @@ func main() {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
+	srv.RegisterOnShutdown(handler.Shutdown)
 
 	go func() {
 		log.Println("Starting Mangaroo server on :8080")
@@ -55,9 +71,81 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Keep going on errors, the scheduler and jobs still need to stop
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	if err := handler.Scheduler.Stop(ctx); err != nil {
+		log.Printf("Subscription scheduler did not stop cleanly: %v", err)
+	}
+
+	if err := handler.Jobs.Shutdown(ctx); err != nil {
+		log.Printf("Background jobs did not stop cleanly: %v", err)
 	}
 
 	log.Println("Server exited gracefully")
 }
+
+// migrate runs the index migrations and prints the report:
+//
+//	mangaroo migrate [-dry-run] [-keep-old]
+func migrate(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report which indices would be migrated")
+	keepOld := flags.Bool("keep-old", cfg.Elasticsearch.KeepOldIndices, "keep the previous index of migrated aliases")
+	flags.Parse(args)
+
+	elasticClient, err := storage.NewElasticClient(cfg.Elasticsearch.URL)
+	if err != nil {
+		log.Fatal("Failed to initialize Elasticsearch client:", err)
+	}
+
+	report, err := elasticClient.Migrate(context.Background(), storage.MigrateOptions{DryRun: *dryRun, KeepOld: *keepOld})
+	printReport(report)
+	if err != nil {
+		log.Fatalf("Index migration failed: %v", err)
+	}
+}
+
+// repairIndices merges page indices that aren't named after their series'
+// source and ID into the right one:
+//
+//	mangaroo repair-indices [-dry-run] [-keep-old]
+func repairIndices(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("repair-indices", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report which indices would be merged")
+	keepOld := flags.Bool("keep-old", false, "keep merged indices instead of deleting them")
+	flags.Parse(args)
+
+	sources, err := client.LoadRegistry(cfg.Downloader.SitesDir)
+	if err != nil {
+		log.Fatal("Failed to load site definitions:", err)
+	}
+	elasticClient, err := storage.NewElasticClient(cfg.Elasticsearch.URL)
+	if err != nil {
+		log.Fatal("Failed to initialize Elasticsearch client:", err)
+	}
+
+	resolve := func(mangaURL string) (string, string, error) {
+		source, err := sources.ForURL(mangaURL)
+		if err != nil {
+			return "", "", err
+		}
+		mangaID, err := source.SeriesID(mangaURL)
+		if err != nil {
+			return "", "", err
+		}
+		return source.Name(), mangaID, nil
+	}
+	report, err := elasticClient.RepairIndices(context.Background(), resolve, storage.RepairOptions{DryRun: *dryRun, KeepOld: *keepOld})
+	printReport(report)
+	if err != nil {
+		log.Fatalf("Index repair failed: %v", err)
+	}
+}
+
+func printReport(report interface{}) {
+	out, _ := json.MarshalIndent(report, "", "  ")
+	os.Stdout.Write(append(out, '\n'))
+}
@@ -16,6 +16,15 @@ func SetupRouter(h *Handler) *chi.Mux {
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/", h.HomeHandler)
 		r.Post("/download", h.DownloadPostHandler)
+		r.Get("/jobs/{id}", h.JobGetHandler)
+		r.Get("/jobs/{id}/events", h.JobEventsHandler)
+		r.Post("/subscriptions", h.SubscriptionPostHandler)
+		r.Get("/subscriptions", h.SubscriptionListHandler)
+		r.Get("/subscriptions/{id}", h.SubscriptionGetHandler)
+		r.Delete("/subscriptions/{id}", h.SubscriptionDeleteHandler)
+		r.Get("/blobs/{hash}", h.BlobGetHandler)
+		r.Get("/manga/search", h.MangaSearchHandler)
+		r.Get("/manga/suggest", h.MangaSuggestHandler)
 
 	})
 
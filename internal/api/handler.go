@@ -1,23 +1,44 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"runtime/debug"
-	"strings"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/go-chi/chi"
+	chiv5 "github.com/go-chi/chi/v5"
 	"github.com/sucumbap/mangaroo/internal/core"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/blob"
 	"github.com/sucumbap/mangaroo/internal/infrastructure/client"
 	"github.com/sucumbap/mangaroo/internal/infrastructure/storage"
+	"github.com/sucumbap/mangaroo/internal/jobs"
 	"github.com/sucumbap/mangaroo/pkg/config"
+	apperrors "github.com/sucumbap/mangaroo/pkg/errors"
 )
 
 type Handler struct {
 	Config        *config.Config
 	Repository    core.MangaRepository
+	Subscriptions core.SubscriptionRepository
 	ElasticClient *storage.ElasticClient
+	Jobs          *jobs.Manager
+	Scheduler     *jobs.Scheduler
+	Blobs         blob.Store
+	Search        storage.ElasticServiceInterface
+	// CatalogIndex is the index Repository stores series in
+	CatalogIndex string
+
+	// done is closed by Shutdown to end the open event streams
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
 func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
@@ -32,16 +53,107 @@ func NewHandler(cfg *config.Config) (*Handler, error) {
 		return nil, fmt.Errorf("failed to initialize Elasticsearch client: %w", err)
 	}
 
+	// Apply index templates, index creation retries this if Elasticsearch
+	// isn't up yet
+	if err := elasticClient.EnsureTemplates(); err != nil {
+		log.Printf("Warning: failed to apply index templates: %v", err)
+	}
+
+	// Aliases keep serving the old indices while they are migrated
+	if cfg.Elasticsearch.MigrateOnStartup {
+		go func() {
+			report, err := elasticClient.Migrate(context.Background(), storage.MigrateOptions{KeepOld: cfg.Elasticsearch.KeepOldIndices})
+			if err != nil {
+				log.Printf("Index migration failed: %v", err)
+				return
+			}
+			log.Printf("Index migration finished: %d migrated, %d up to date", len(report.Migrations), report.UpToDate)
+		}()
+	}
+
 	// Initialize Repository
 	repository := storage.NewElasticMangaRepository(elasticClient, "mangaroo")
 
+	// Load site definitions
+	sources, err := client.LoadRegistry(cfg.Downloader.SitesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load site definitions: %w", err)
+	}
+
+	// Initialize job state store
+	stateDir := cfg.Jobs.StateDir
+	if stateDir == "" {
+		stateDir = filepath.Join(cfg.Downloader.OutputFolder, ".jobs")
+	}
+	store, err := jobs.NewStore(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize job store: %w", err)
+	}
+
+	// Initialize blob storage for page images
+	blobs, err := newBlobStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize blob storage: %w", err)
+	}
+
+	// Initialize background job manager
+	jobManager := jobs.NewManager(cfg, elasticClient, repository, sources, store, blobs)
+	jobManager.Start()
+
+	// Initialize subscription scheduler
+	subscriptions := storage.NewElasticSubscriptionRepository(elasticClient, "mangaroo")
+	scheduler := jobs.NewScheduler(jobManager, subscriptions, cfg.Scheduler.PollInterval)
+	scheduler.Start()
+
 	return &Handler{
 		Config:        cfg,
 		Repository:    repository,
+		Subscriptions: subscriptions,
 		ElasticClient: elasticClient,
+		Jobs:          jobManager,
+		Scheduler:     scheduler,
+		Blobs:         blobs,
+		Search:        storage.NewElasticService(elasticClient),
+		CatalogIndex:  repository.IndexName(),
+		done:          make(chan struct{}),
 	}, nil
 }
 
+// Shutdown ends the open event streams, which would otherwise keep the
+// server from shutting down until their clients disconnect. Register it
+// with http.Server.RegisterOnShutdown.
+func (h *Handler) Shutdown() {
+	h.doneOnce.Do(func() { close(h.done) })
+}
+
+// newBlobStore opens the blob store selected by the configuration.
+func newBlobStore(cfg *config.Config) (blob.Store, error) {
+	switch cfg.Storage.Backend {
+	case "", "local":
+		dir := cfg.Storage.Dir
+		if dir == "" {
+			dir = filepath.Join(cfg.Downloader.OutputFolder, "blobs")
+		}
+		return blob.NewLocalStore(dir)
+	case "s3":
+		s3 := cfg.Storage.S3
+		return blob.NewS3Store(context.Background(), blob.S3Options{
+			Endpoint:       s3.Endpoint,
+			Region:         s3.Region,
+			Bucket:         s3.Bucket,
+			Prefix:         s3.Prefix,
+			AccessKey:      s3.AccessKey,
+			SecretKey:      s3.SecretKey,
+			UseSSL:         s3.UseSSL,
+			PartSize:       s3.PartSize,
+			PublicEndpoint: s3.PublicEndpoint,
+			PresignExpiry:  s3.PresignExpiry,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
+
 func (h *Handler) DownloadPostHandler(w http.ResponseWriter, r *http.Request) {
 	urlParam := r.URL.Query().Get("url")
 	if urlParam == "" {
@@ -50,17 +162,18 @@ func (h *Handler) DownloadPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Download request received for URL: %s", urlParam)
-
-	// Extract manga ID from URL
-	var mangaID string
-	if parts := strings.Split(urlParam, "/manga/"); len(parts) > 1 {
-		mangaID = strings.TrimSuffix(parts[1], "/")
-	} else {
-		log.Println("Could not extract manga ID from URL, using 'unknown'")
-		mangaID = "unknown"
+	mode := core.JobMode(r.URL.Query().Get("mode"))
+	switch mode {
+	case "":
+		mode = core.JobModeFull
+	case core.JobModeFull, core.JobModeUpdate:
+	default:
+		http.Error(w, fmt.Sprintf("Invalid mode %q, expected %q or %q", mode, core.JobModeFull, core.JobModeUpdate), http.StatusBadRequest)
+		return
 	}
 
+	log.Printf("Download request received for URL: %s (mode: %s)", urlParam, mode)
+
 	// Test Elasticsearch connection
 	log.Println("Testing Elasticsearch connection...")
 	if err := h.ElasticClient.Ping(); err != nil {
@@ -70,86 +183,327 @@ func (h *Handler) DownloadPostHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Println("Elasticsearch connection successful")
 
-	config := client.Config{
-		BaseURL:      urlParam,
-		OutputFolder: h.Config.Downloader.OutputFolder,
-		UserAgent:    h.Config.Downloader.UserAgent,
+	job, err := h.Jobs.Submit(urlParam, mode)
+	if err != nil {
+		log.Printf("Failed to queue download: %v", err)
+		if errors.Is(err, client.ErrUnsupportedSource) || errors.Is(err, client.ErrInvalidSeriesURL) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, jobs.ErrQueueFull) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(err, jobs.ErrJobActive) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to queue download: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	var downloader *client.MangaDownloader
-	var err error
+	statusURL := "/api/v1/jobs/" + job.ID
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", statusURL)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     string(job.State),
+		"message":    "Download queued",
+		"job_id":     job.ID,
+		"status_url": statusURL,
+	})
+}
 
-	// Initialize downloader with manga ID
-	log.Println("Initializing manga downloader...")
-	downloader, err = client.NewMangaDownloader(config, mangaID)
-	if err != nil {
-		log.Printf("Failed to initialize downloader: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to initialize downloader: %v", err), http.StatusInternalServerError)
+func (h *Handler) JobGetHandler(w http.ResponseWriter, r *http.Request) {
+	id := urlParam(r, "id")
+
+	job, ok := h.Jobs.Get(id)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
 		return
 	}
-	defer func() {
-		log.Println("Cleaning up resources...")
-		if r := recover(); r != nil {
-			log.Printf("PANIC RECOVERED in DownloadPostHandler: %v", r)
-			debug.PrintStack() // Print stack trace for debugging
-			http.Error(w, "An internal server error occurred", http.StatusInternalServerError)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func (h *Handler) JobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	id := urlParam(r, "id")
+
+	history, events, cancel, ok := h.Jobs.Subscribe(id)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	defer cancel()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Could not clear write deadline for event stream: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range history {
+		if err := writeSSE(w, event); err != nil {
+			return
 		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
 
-		if downloader != nil {
-			log.Println("Closing downloader...")
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("PANIC RECOVERED in downloader.Close(): %v", r)
-					}
-				}()
-				downloader.Close()
-			}()
-			log.Println("Downloader closed")
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
 		}
-	}()
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event core.ProgressEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+func (h *Handler) SubscriptionPostHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL      string `json:"url"`
+		Schedule string `json:"schedule"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.URL == "" || req.Schedule == "" {
+		http.Error(w, "url and schedule are required", http.StatusBadRequest)
+		return
+	}
 
-	// Set Elasticsearch client
-	downloader.SetElasticClient(h.ElasticClient)
+	if _, err := jobs.ParseSchedule(req.Schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.Jobs.ValidateURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Get manga title
-	mangaTitle, err := downloader.GetMangaTitle()
+	subscription, err := h.Scheduler.Subscribe(req.URL, req.Schedule)
 	if err != nil {
-		log.Printf("Warning: Could not get manga title: %v", err)
-		mangaTitle = "unknown"
+		log.Printf("Failed to create subscription: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to create subscription: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	// Create manga-specific index name
-	indexName := h.ElasticClient.GetMangaIndexName(mangaTitle, mangaID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/subscriptions/"+subscription.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
 
-	// Ensure index exists
-	if err := h.ElasticClient.EnsureIndex(indexName); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to ensure index exists: %v", err), http.StatusInternalServerError)
+func (h *Handler) SubscriptionListHandler(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.Subscriptions.GetAllSubscriptions()
+	if err != nil {
+		log.Printf("Failed to list subscriptions: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list subscriptions: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Start download process
-	if err := downloader.Run(); err != nil {
-		http.Error(w, fmt.Sprintf("Download failed: %v", err), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+func (h *Handler) SubscriptionGetHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.Subscriptions.GetSubscriptionByID(urlParam(r, "id"))
+	if err != nil {
+		if apperrors.IsNotFound(err) {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get subscription: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+func (h *Handler) SubscriptionDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.Subscriptions.DeleteSubscription(urlParam(r, "id")); err != nil {
+		if apperrors.IsNotFound(err) {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to delete subscription: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Save manga metadata to repository
-	manga := core.Manga{
-		ID:    mangaID,
-		Title: mangaTitle,
-		// Add other metadata...
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MangaSearchHandler searches the catalog:
+//
+//	GET /api/v1/manga/search?q=&genre=&author=&status=&from=&size=
+//
+// genre and author may be repeated, every value has to match.
+func (h *Handler) MangaSearchHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := core.MangaQuery{
+		Text:    params.Get("q"),
+		Genres:  params["genre"],
+		Authors: params["author"],
+		Status:  params.Get("status"),
 	}
 
-	if err := h.Repository.SaveManga(manga); err != nil {
-		log.Printf("Warning: Failed to save manga metadata: %v", err)
-		// Continue anyway as we've already downloaded the images
+	var err error
+	if query.From, err = intParam(params.Get("from"), 0); err != nil || query.From < 0 {
+		http.Error(w, "from must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	if query.Size, err = intParam(params.Get("size"), 20); err != nil || query.Size < 1 || query.Size > 100 {
+		http.Error(w, "size must be between 1 and 100", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Search.Search(h.CatalogIndex, query)
+	if err != nil {
+		log.Printf("Failed to search manga: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to search manga: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": "Download complete",
-		"index":   indexName,
-	})
+	json.NewEncoder(w).Encode(result)
+}
+
+// MangaSuggestHandler completes partial or misspelled titles:
+//
+//	GET /api/v1/manga/suggest?q=&size=
+func (h *Handler) MangaSuggestHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("q")
+	if prefix == "" {
+		http.Error(w, "q parameter is required", http.StatusBadRequest)
+		return
+	}
+	size, err := intParam(r.URL.Query().Get("size"), 10)
+	if err != nil || size < 1 || size > 50 {
+		http.Error(w, "size must be between 1 and 50", http.StatusBadRequest)
+		return
+	}
+
+	suggestions, err := h.Search.Suggest(h.CatalogIndex, prefix, size)
+	if err != nil {
+		log.Printf("Failed to suggest titles: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to suggest titles: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for i := range suggestions {
+		if suggestions[i].ThumbnailHash != "" {
+			suggestions[i].ThumbnailURL = "/api/v1/blobs/" + suggestions[i].ThumbnailHash
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+func intParam(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// BlobGetHandler serves a page image by its content hash. Content never
+// changes under a hash, so it can be cached forever.
+func (h *Handler) BlobGetHandler(w http.ResponseWriter, r *http.Request) {
+	hash := urlParam(r, "hash")
+
+	// Let the client download straight from object storage when it is
+	// publicly reachable
+	if presigner, ok := h.Blobs.(blob.Presigner); ok {
+		signed, err := presigner.PresignedURL(r.Context(), hash)
+		switch {
+		case errors.Is(err, blob.ErrNoPublicURL):
+			// Served below
+		case errors.Is(err, blob.ErrNotFound):
+			http.Error(w, "Blob not found", http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, fmt.Sprintf("Failed to sign blob URL: %v", err), http.StatusInternalServerError)
+			return
+		default:
+			exists, err := h.Blobs.Exists(r.Context(), hash)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to check blob: %v", err), http.StatusInternalServerError)
+				return
+			}
+			if !exists {
+				http.Error(w, "Blob not found", http.StatusNotFound)
+				return
+			}
+			http.Redirect(w, r, signed, http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	content, err := h.Blobs.Open(r.Context(), hash)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			http.Error(w, "Blob not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to open blob: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	if seeker, ok := content.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, hash, time.Time{}, seeker)
+		return
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Failed to send blob %s: %v", hash, err)
+	}
+}
+
+// urlParam reads a route parameter from either of the chi routers.
+func urlParam(r *http.Request, key string) string {
+	if value := chiv5.URLParam(r, key); value != "" {
+		return value
+	}
+	return chi.URLParam(r, key)
 }
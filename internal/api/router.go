@@ -21,6 +21,15 @@ func NewRouter(handler *Handler) http.Handler {
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/", handler.HomeHandler)
 		r.Post("/download", handler.DownloadPostHandler)
+		r.Get("/jobs/{id}", handler.JobGetHandler)
+		r.Get("/jobs/{id}/events", handler.JobEventsHandler)
+		r.Post("/subscriptions", handler.SubscriptionPostHandler)
+		r.Get("/subscriptions", handler.SubscriptionListHandler)
+		r.Get("/subscriptions/{id}", handler.SubscriptionGetHandler)
+		r.Delete("/subscriptions/{id}", handler.SubscriptionDeleteHandler)
+		r.Get("/blobs/{hash}", handler.BlobGetHandler)
+		r.Get("/manga/search", handler.MangaSearchHandler)
+		r.Get("/manga/suggest", handler.MangaSuggestHandler)
 	})
 
 	return r
@@ -1,19 +1,76 @@
 package core
 
+import "time"
+
 type Manga struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Authors     []string  `json:"authors"`
-	Genres      []string  `json:"genres"`
-	CoverPath   string    `json:"cover_path"`
-	Chapters    []Chapter `json:"chapters"`
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	AltTitles   []string `json:"alt_titles"`
+	URL         string   `json:"url"`
+	Source      string   `json:"source"`
+	Status      string   `json:"status"`
+	Description string   `json:"description"`
+	Authors     []string `json:"authors"`
+	Genres      []string `json:"genres"`
+	CoverURL    string   `json:"cover_url"`
+	// CoverHash references the cover image in the blob store
+	CoverHash string `json:"cover_hash,omitempty"`
+	// CoverThumbHash references a small JPEG version of the cover
+	CoverThumbHash string    `json:"cover_thumb_hash,omitempty"`
+	Chapters       []Chapter `json:"chapters"`
+	// IndexName is the index holding the series' pages
+	IndexName string    `json:"index_name,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MangaQuery searches the catalog. Text matches titles, alternative
+// titles and descriptions, every filter value must match.
+type MangaQuery struct {
+	Text    string
+	Genres  []string
+	Authors []string
+	Status  string
+	From    int
+	Size    int
+}
+
+// MangaSearchResult is a page of catalog hits. Facets count the values of
+// every matching series, not only the returned page.
+type MangaSearchResult struct {
+	Total  int64                   `json:"total"`
+	Hits   []MangaHit              `json:"hits"`
+	Facets map[string][]FacetCount `json:"facets"`
+}
+
+// MangaHit is a matching series. Chapters are left out, highlights hold
+// the matched fragments per field with matches wrapped in <em>.
+type MangaHit struct {
+	Manga      Manga               `json:"manga"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// MangaSuggestion is a title completion. The thumbnail is the cover
+// thumbnail, or the full cover for series saved before thumbnails existed.
+type MangaSuggestion struct {
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	Source        string  `json:"source"`
+	Score         float64 `json:"score"`
+	ThumbnailHash string  `json:"thumbnail_hash,omitempty"`
+	ThumbnailURL  string  `json:"thumbnail_url,omitempty"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
 }
 
 type Chapter struct {
 	ID       string `json:"id"`
 	Title    string `json:"title"`
 	Number   string `json:"number"`
+	URL      string `json:"url"`
 	Pages    []Page `json:"pages"`
 	Uploaded string `json:"uploaded"`
 }
@@ -22,3 +79,111 @@ type Page struct {
 	Number int    `json:"number"`
 	Path   string `json:"path"`
 }
+
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+type JobMode string
+
+const (
+	// JobModeFull downloads every chapter of the series
+	JobModeFull JobMode = "full"
+	// JobModeUpdate only downloads chapters missing from the index
+	JobModeUpdate JobMode = "update"
+)
+
+// DownloadSummary reports what a download changed.
+type DownloadSummary struct {
+	Added   []string `json:"added"`
+	Failed  []string `json:"failed"`
+	Skipped int      `json:"skipped"`
+	// FailedPages lists the pages that still failed after all retries
+	FailedPages []FailedPage `json:"failed_pages,omitempty"`
+}
+
+// FailedPage is a page that could not be downloaded.
+type FailedPage struct {
+	Chapter string `json:"chapter"`
+	Page    int    `json:"page"`
+	URL     string `json:"url"`
+	Error   string `json:"error"`
+}
+
+type Job struct {
+	ID             string           `json:"id"`
+	URL            string           `json:"url"`
+	MangaID        string           `json:"manga_id"`
+	Source         string           `json:"source"`
+	Mode           JobMode          `json:"mode"`
+	State          JobState         `json:"state"`
+	ChaptersTotal  int              `json:"chapters_total"`
+	ChaptersDone   int              `json:"chapters_done"`
+	ChaptersFailed int              `json:"chapters_failed"`
+	IndexName      string           `json:"index_name,omitempty"`
+	Summary        *DownloadSummary `json:"summary,omitempty"`
+	Checkpoint     *Checkpoint      `json:"checkpoint,omitempty"`
+	Error          string           `json:"error,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	StartedAt      *time.Time       `json:"started_at,omitempty"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job reached a terminal state.
+func (j Job) Finished() bool {
+	return j.State == JobSucceeded || j.State == JobFailed
+}
+
+type ProgressEventType string
+
+const (
+	EventJobStarted      ProgressEventType = "job_started"
+	EventChapterStarted  ProgressEventType = "chapter_started"
+	EventPageDownloaded  ProgressEventType = "page_downloaded"
+	EventPageFailed      ProgressEventType = "page_failed"
+	EventPageIndexed     ProgressEventType = "page_indexed"
+	EventChapterFinished ProgressEventType = "chapter_finished"
+	EventChapterFailed   ProgressEventType = "chapter_failed"
+	EventJobFinished     ProgressEventType = "job_finished"
+)
+
+// ProgressEvent describes a single step of a running download. Chapter
+// counters are filled on job and chapter level events.
+type ProgressEvent struct {
+	Type           ProgressEventType `json:"type"`
+	JobID          string            `json:"job_id,omitempty"`
+	Chapter        string            `json:"chapter,omitempty"`
+	Page           int               `json:"page,omitempty"`
+	TotalPages     int               `json:"total_pages,omitempty"`
+	ChaptersDone   int               `json:"chapters_done"`
+	ChaptersFailed int               `json:"chapters_failed"`
+	ChaptersTotal  int               `json:"chapters_total"`
+	State          JobState          `json:"state,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	Time           time.Time         `json:"time"`
+}
+
+// Subscription periodically checks a series for new chapters.
+type Subscription struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Schedule      string           `json:"schedule"`
+	CreatedAt     time.Time        `json:"created_at"`
+	NextCheckAt   time.Time        `json:"next_check_at"`
+	LastCheckedAt *time.Time       `json:"last_checked_at,omitempty"`
+	LastJobID     string           `json:"last_job_id,omitempty"`
+	LastState     JobState         `json:"last_state,omitempty"`
+	LastError     string           `json:"last_error,omitempty"`
+	LastSummary   *DownloadSummary `json:"last_summary,omitempty"`
+}
+
+// Checkpoint records how far a job got so it can resume after a restart.
+type Checkpoint struct {
+	CompletedChapters []string  `json:"completed_chapters"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
@@ -2,7 +2,14 @@ package core
 
 type MangaRepository interface {
 	SaveManga(manga Manga) error
-	GetMangaByID(id string) (Manga, error)
+	GetMangaByID(source, id string) (Manga, error)
 	GetAllManga() ([]Manga, error)
-	DeleteManga(id string) error
+	DeleteManga(source, id string) error
+}
+
+type SubscriptionRepository interface {
+	SaveSubscription(subscription Subscription) error
+	GetSubscriptionByID(id string) (Subscription, error)
+	GetAllSubscriptions() ([]Subscription, error)
+	DeleteSubscription(id string) error
 }
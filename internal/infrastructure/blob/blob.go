@@ -0,0 +1,111 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+
+	// Register the decoders used to read page dimensions
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Info describes a stored blob. Blobs are addressed by the SHA-256 of
+// their content, so storing the same page twice keeps a single copy.
+type Info struct {
+	Hash        string `json:"hash"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+}
+
+// Store keeps page images outside of Elasticsearch.
+type Store interface {
+	// Put stores the file at path and reports whether its content was
+	// already present
+	Put(ctx context.Context, path string) (Info, bool, error)
+	// Open returns the content stored under hash
+	Open(ctx context.Context, hash string) (io.ReadCloser, error)
+	// Exists reports whether content is stored under hash
+	Exists(ctx context.Context, hash string) (bool, error)
+	// Delete removes the content stored under hash
+	Delete(ctx context.Context, hash string) error
+}
+
+// Presigner is implemented by stores that can hand out direct download
+// URLs, so blobs don't have to be proxied through the API.
+type Presigner interface {
+	PresignedURL(ctx context.Context, hash string) (string, error)
+}
+
+// ErrNoPublicURL is returned by a Presigner that has no URL clients can
+// reach, the blob has to be served through the API.
+var ErrNoPublicURL = fmt.Errorf("blob store has no public URL")
+
+// ErrNotFound is returned when no blob is stored under a hash.
+var ErrNotFound = fmt.Errorf("blob not found")
+
+// Describe hashes the file at path and reads its content type and, for
+// images, its dimensions.
+func Describe(path string) (Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// Keep the head of the file to sniff the type and decode the size
+	var head bytes.Buffer
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(hasher, &limitedWriter{buf: &head, max: 512 * 1024}), file)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	info := Info{
+		Hash:        hex.EncodeToString(hasher.Sum(nil)),
+		Size:        size,
+		ContentType: http.DetectContentType(head.Bytes()),
+	}
+	if config, _, err := image.DecodeConfig(bytes.NewReader(head.Bytes())); err == nil {
+		info.Width = config.Width
+		info.Height = config.Height
+	}
+	return info, nil
+}
+
+// ValidHash reports whether hash looks like a hex encoded SHA-256.
+func ValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// limitedWriter keeps the first max bytes written to it and drops the rest.
+type limitedWriter struct {
+	buf *bytes.Buffer
+	max int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if room := w.max - w.buf.Len(); room > 0 {
+		if len(p) > room {
+			w.buf.Write(p[:room])
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,111 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps blobs on the local filesystem, fanned out into
+// sub folders by the first characters of the hash.
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("error creating blob folder: %w", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+func (s *LocalStore) path(hash string) string {
+	return filepath.Join(s.root, hash[:2], hash[2:4], hash)
+}
+
+func (s *LocalStore) Put(ctx context.Context, path string) (Info, bool, error) {
+	info, err := Describe(path)
+	if err != nil {
+		return Info{}, false, err
+	}
+
+	target := s.path(info.Hash)
+	if _, err := os.Stat(target); err == nil {
+		return info, true, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return Info{}, false, fmt.Errorf("error creating blob folder: %w", err)
+	}
+
+	// Copy into a temp file next to the target so the rename is atomic
+	src, err := os.Open(path)
+	if err != nil {
+		return Info{}, false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), info.Hash+".*.tmp")
+	if err != nil {
+		return Info{}, false, fmt.Errorf("failed to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return Info{}, false, fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return Info{}, false, fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return Info{}, false, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return info, false, nil
+}
+
+func (s *LocalStore) Open(ctx context.Context, hash string) (io.ReadCloser, error) {
+	if !ValidHash(hash) {
+		return nil, ErrNotFound
+	}
+
+	file, err := os.Open(s.path(hash))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+	return file, nil
+}
+
+func (s *LocalStore) Exists(ctx context.Context, hash string) (bool, error) {
+	if !ValidHash(hash) {
+		return false, nil
+	}
+
+	_, err := os.Stat(s.path(hash))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check blob: %w", err)
+	}
+	return true, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, hash string) error {
+	if !ValidHash(hash) {
+		return ErrNotFound
+	}
+
+	if err := os.Remove(s.path(hash)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Options configures an S3Store.
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	// PartSize is the multipart chunk size, larger files are uploaded in parts
+	PartSize uint64
+	// PublicEndpoint is the URL clients reach the bucket's server at,
+	// such as "https://files.example.com". Without it no URLs are
+	// presigned since Endpoint is usually only reachable internally.
+	PublicEndpoint string
+	// PresignExpiry is how long presigned URLs stay valid
+	PresignExpiry time.Duration
+}
+
+// S3Store keeps blobs in an S3 compatible bucket such as MinIO.
+type S3Store struct {
+	client        *minio.Client
+	bucket        string
+	prefix        string
+	partSize      uint64
+	presignExpiry time.Duration
+	// presign signs URLs for the public endpoint, nil without one
+	presign *minio.Client
+}
+
+// NewS3Store connects to the bucket, creating it if it doesn't exist.
+func NewS3Store(ctx context.Context, opts S3Options) (*S3Store, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, fmt.Errorf("S3 endpoint and bucket are required")
+	}
+
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure: opts.UseSSL,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, opts.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", opts.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, opts.Bucket, minio.MakeBucketOptions{Region: opts.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", opts.Bucket, err)
+		}
+		log.Printf("Created bucket: %s", opts.Bucket)
+	}
+
+	var presign *minio.Client
+	if opts.PublicEndpoint != "" {
+		if presign, err = newPresignClient(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.PresignExpiry <= 0 {
+		opts.PresignExpiry = 15 * time.Minute
+	}
+
+	return &S3Store{
+		client:        client,
+		presign:       presign,
+		bucket:        opts.Bucket,
+		prefix:        opts.Prefix,
+		partSize:      opts.PartSize,
+		presignExpiry: opts.PresignExpiry,
+	}, nil
+}
+
+// newPresignClient creates the client signing URLs for the public
+// endpoint. Signing doesn't contact the server as long as the region is
+// known.
+func newPresignClient(opts S3Options) (*minio.Client, error) {
+	public, err := url.Parse(opts.PublicEndpoint)
+	if err != nil || public.Host == "" || (public.Scheme != "http" && public.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 public endpoint %q, expected an http(s) URL", opts.PublicEndpoint)
+	}
+
+	region := opts.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	client, err := minio.New(public.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure: public.Scheme == "https",
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 presign client: %w", err)
+	}
+	return client, nil
+}
+
+func (s *S3Store) key(hash string) string {
+	return path.Join(s.prefix, hash[:2], hash[2:4], hash)
+}
+
+func (s *S3Store) Put(ctx context.Context, filePath string) (Info, bool, error) {
+	info, err := Describe(filePath)
+	if err != nil {
+		return Info{}, false, err
+	}
+
+	exists, err := s.Exists(ctx, info.Hash)
+	if err != nil {
+		return Info{}, false, err
+	}
+	if exists {
+		return info, true, nil
+	}
+
+	// Files above PartSize are sent as a multipart upload
+	_, err = s.client.FPutObject(ctx, s.bucket, s.key(info.Hash), filePath, minio.PutObjectOptions{
+		ContentType:  info.ContentType,
+		CacheControl: "public, max-age=31536000, immutable",
+		PartSize:     s.partSize,
+	})
+	if err != nil {
+		return Info{}, false, fmt.Errorf("failed to upload blob: %w", err)
+	}
+	return info, false, nil
+}
+
+func (s *S3Store) Open(ctx context.Context, hash string) (io.ReadCloser, error) {
+	if !ValidHash(hash) {
+		return nil, ErrNotFound
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, s.key(hash), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+
+	// GetObject is lazy, stat it to find out whether it exists
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if isNoSuchKey(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+	return object, nil
+}
+
+func (s *S3Store) Exists(ctx context.Context, hash string) (bool, error) {
+	if !ValidHash(hash) {
+		return false, nil
+	}
+
+	if _, err := s.client.StatObject(ctx, s.bucket, s.key(hash), minio.StatObjectOptions{}); err != nil {
+		if isNoSuchKey(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check blob: %w", err)
+	}
+	return true, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, hash string) error {
+	exists, err := s.Exists(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, s.key(hash), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+// PresignedURL returns a time limited URL to download the blob straight
+// from the bucket through the public endpoint. It fails with
+// ErrNoPublicURL when none is configured.
+func (s *S3Store) PresignedURL(ctx context.Context, hash string) (string, error) {
+	if s.presign == nil {
+		return "", ErrNoPublicURL
+	}
+	if !ValidHash(hash) {
+		return "", ErrNotFound
+	}
+
+	u, err := s.presign.PresignedGetObject(ctx, s.bucket, s.key(hash), s.presignExpiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign blob URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+func isNoSuchKey(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
@@ -0,0 +1,48 @@
+package blob
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+
+	"golang.org/x/image/draw"
+)
+
+// Thumbnail writes a JPEG copy of the image at src to dst, scaled down to
+// at most maxWidth pixels wide.
+func Thumbnail(src, dst string, maxWidth int) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	img, _, err := image.Decode(in)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", src, err)
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Src, nil)
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(out, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,44 @@
+package browser
+
+import "time"
+
+// Scraping backends a site can be driven with.
+const (
+	BackendChrome = "chrome"
+	BackendHTTP   = "http"
+)
+
+// Field describes how to read a value from an element. Without Attrs the
+// trimmed text content is used.
+type Field struct {
+	// Selector is relative to the matched element, empty means the element itself
+	Selector     string   `json:"selector,omitempty"`
+	Attrs        []string `json:"attrs,omitempty"`
+	SkipPrefixes []string `json:"skip,omitempty"`
+	// Resolve turns relative URLs into absolute ones
+	Resolve bool `json:"resolve,omitempty"`
+}
+
+// Browser is the contract shared by the scraping backends: load a page,
+// then query its DOM.
+type Browser interface {
+	// Navigate loads a URL
+	Navigate(url string) error
+	// Bsleep lets the page settle for a number of seconds
+	Bsleep(seconds int) error
+	// WaitVisible waits until an element matching selector is visible
+	WaitVisible(selector string, timeout time.Duration) error
+	// WaitCount waits until at least count elements match selector
+	WaitCount(selector string, count int, timeout time.Duration) error
+	// WaitNetworkIdle waits until no request has been in flight for idle
+	WaitNetworkIdle(idle, timeout time.Duration) error
+	// AutoScroll scrolls until the number of matches with a value for
+	// field stops growing and returns it
+	AutoScroll(selector string, field Field, timeout time.Duration) (int, error)
+	// Location returns the URL of the current page after redirects
+	Location() (string, error)
+	// Extract reads the given fields from every element matching selector
+	Extract(selector string, fields map[string]Field) ([]map[string]string, error)
+	// Close releases the resources of the backend
+	Close()
+}
@@ -2,11 +2,13 @@ package browser
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/chromedp/chromedp"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/ratelimit"
 )
 
 // this packeges purpose is to wrap the chromedp package into an interface
@@ -20,6 +22,10 @@ type ChromeDP_CTX struct {
 type ChromeDP struct {
 	ChromeDP_CTX
 	ChromeDP_Options []chromedp.ExecAllocatorOption
+	// Limiter throttles navigations per host, nil means no limit
+	Limiter *ratelimit.Limiter
+
+	network *networkTracker
 }
 type ChromeDPInterface interface {
 	// Initialize the ChromeDP context
@@ -34,6 +40,14 @@ type ChromeDPInterface interface {
 	Evaluate(expression string) (string, error)
 	// Sleep for a specified number of seconds
 	Bsleep(seconds int) error
+	// Wait until an element matching the selector is visible
+	WaitVisible(selector string, timeout time.Duration) error
+	// Wait until at least count elements match the selector
+	WaitCount(selector string, count int, timeout time.Duration) error
+	// Wait until no request has been in flight for the idle duration
+	WaitNetworkIdle(idle, timeout time.Duration) error
+	// Scroll through the page until lazy loaded matches stop appearing
+	AutoScroll(selector string, field Field, timeout time.Duration) (int, error)
 }
 
 func (cdp *ChromeDP) InitChromeDP() (ChromeDP_CTX, error) {
@@ -91,6 +105,7 @@ func (cdp *ChromeDP) InitChromeDP() (ChromeDP_CTX, error) {
 	cdp.Ctx = browserCtx
 	cdp.CancelAlloc = cancelAlloc
 	cdp.CancelCtx = cancelCtx
+	cdp.trackNetwork()
 
 	return ChromeDP_CTX{
 		AllocCtx:    allocCtx,
@@ -156,6 +171,10 @@ func (cdp *ChromeDP) Navigate(url string) error {
 		return fmt.Errorf("cannot navigate: ChromeDP not properly initialized")
 	}
 
+	if err := cdp.Limiter.Wait(cdp.Ctx, url); err != nil {
+		return fmt.Errorf("rate limiter: %w", err)
+	}
+
 	log.Printf("Navigating to: %s", url)
 	err := chromedp.Run(cdp.Ctx, chromedp.Navigate(url))
 	if err != nil {
@@ -191,3 +210,56 @@ func (cdp *ChromeDP) Bsleep(seconds int) error {
 	}
 	return nil
 }
+
+// Close implements Browser.
+func (cdp *ChromeDP) Close() {
+	cdp.CloseChromeDP()
+}
+
+func (cdp *ChromeDP) Location() (string, error) {
+	return cdp.Evaluate(`location.href`)
+}
+
+const extractScript = `(() => {
+    const spec = %s;
+    const pick = (el, f) => {
+        const target = f.selector ? el.querySelector(f.selector) : el;
+        if (!target) return "";
+        if (!f.attrs || f.attrs.length === 0) return target.textContent.trim();
+        for (const attr of f.attrs) {
+            const value = (target.getAttribute(attr) || "").trim();
+            if (!value || (f.skip || []).some(p => value.startsWith(p))) continue;
+            if (!f.resolve) return value;
+            try { return new URL(value, document.baseURI).href; } catch (e) { return value; }
+        }
+        return "";
+    };
+    return JSON.stringify(Array.from(document.querySelectorAll(spec.selector)).map(el => {
+        const out = {};
+        for (const [name, f] of Object.entries(spec.fields)) out[name] = pick(el, f);
+        return out;
+    }));
+})()`
+
+// Extract runs the selection in the page so content rendered by scripts
+// is included.
+func (cdp *ChromeDP) Extract(selector string, fields map[string]Field) ([]map[string]string, error) {
+	spec, err := json.Marshal(map[string]interface{}{
+		"selector": selector,
+		"fields":   fields,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := cdp.Evaluate(fmt.Sprintf(extractScript, spec))
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate JavaScript: %w", err)
+	}
+
+	var rows []map[string]string
+	if err := json.Unmarshal([]byte(result), &rows); err != nil {
+		return nil, fmt.Errorf("failed to parse extracted values: %w", err)
+	}
+	return rows, nil
+}
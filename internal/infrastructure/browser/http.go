@@ -0,0 +1,212 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/ratelimit"
+	"golang.org/x/net/html"
+)
+
+// HTTPBrowser fetches pages with net/http and queries the parsed HTML. It
+// runs no JavaScript, so it only suits server rendered sites, but needs no
+// Chromium.
+type HTTPBrowser struct {
+	client    *http.Client
+	userAgent string
+	limiter   *ratelimit.Limiter
+
+	doc  *html.Node
+	base *url.URL
+}
+
+func NewHTTPBrowser(userAgent string, limiter *ratelimit.Limiter) *HTTPBrowser {
+	jar, _ := cookiejar.New(nil)
+	return &HTTPBrowser{
+		client:    &http.Client{Timeout: 30 * time.Second, Jar: jar},
+		userAgent: userAgent,
+		limiter:   limiter,
+	}
+}
+
+func (h *HTTPBrowser) Navigate(pageURL string) error {
+	if err := h.limiter.Wait(context.Background(), pageURL); err != nil {
+		return fmt.Errorf("rate limiter: %w", err)
+	}
+
+	log.Printf("Fetching: %s", pageURL)
+	req, err := http.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", h.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	h.doc = doc
+	h.base = resp.Request.URL
+	if baseTag := cascadia.Query(doc, baseSelector); baseTag != nil {
+		if ref, err := h.base.Parse(attr(baseTag, "href")); err == nil {
+			h.base = ref
+		}
+	}
+	return nil
+}
+
+var baseSelector = cascadia.MustCompile("base[href]")
+
+// Bsleep returns immediately, a fetched page is already complete.
+func (h *HTTPBrowser) Bsleep(seconds int) error {
+	return nil
+}
+
+// WaitVisible only checks that selector matches, a fetched page doesn't
+// change and has no layout to be visible in.
+func (h *HTTPBrowser) WaitVisible(selector string, timeout time.Duration) error {
+	return h.WaitCount(selector, 1, timeout)
+}
+
+// WaitCount checks the number of matches right away since waiting would
+// not change it.
+func (h *HTTPBrowser) WaitCount(selector string, count int, timeout time.Duration) error {
+	if h.doc == nil {
+		return fmt.Errorf("no page loaded, call Navigate first")
+	}
+
+	sel, err := cascadia.Compile(selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	if found := len(cascadia.QueryAll(h.doc, sel)); found < count {
+		return fmt.Errorf("waiting for %d matches of %q: found %d", count, selector, found)
+	}
+	return nil
+}
+
+// WaitNetworkIdle returns immediately, the page made no further requests.
+func (h *HTTPBrowser) WaitNetworkIdle(idle, timeout time.Duration) error {
+	return nil
+}
+
+func (h *HTTPBrowser) Location() (string, error) {
+	if h.doc == nil {
+		return "", fmt.Errorf("no page loaded, call Navigate first")
+	}
+	return h.base.String(), nil
+}
+
+func (h *HTTPBrowser) Extract(selector string, fields map[string]Field) ([]map[string]string, error) {
+	if h.doc == nil {
+		return nil, fmt.Errorf("no page loaded, call Navigate first")
+	}
+
+	sel, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	fieldSels := make(map[string]cascadia.Selector, len(fields))
+	for name, f := range fields {
+		if f.Selector == "" {
+			continue
+		}
+		if fieldSels[name], err = cascadia.Compile(f.Selector); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", f.Selector, err)
+		}
+	}
+
+	matches := cascadia.QueryAll(h.doc, sel)
+	rows := make([]map[string]string, 0, len(matches))
+	for _, el := range matches {
+		row := make(map[string]string, len(fields))
+		for name, f := range fields {
+			target := el
+			if s, ok := fieldSels[name]; ok {
+				target = cascadia.Query(el, s)
+			}
+			row[name] = h.pick(target, f)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (h *HTTPBrowser) Close() {}
+
+// pick mirrors the selection rules of the Chrome extraction script.
+func (h *HTTPBrowser) pick(el *html.Node, f Field) string {
+	if el == nil {
+		return ""
+	}
+	if len(f.Attrs) == 0 {
+		return strings.TrimSpace(textContent(el))
+	}
+
+	for _, name := range f.Attrs {
+		value := strings.TrimSpace(attr(el, name))
+		if value == "" || hasAnyPrefix(value, f.SkipPrefixes) {
+			continue
+		}
+		if !f.Resolve {
+			return value
+		}
+		if ref, err := h.base.Parse(value); err == nil {
+			return ref.String()
+		}
+		return value
+	}
+	return ""
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(value, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,440 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/ratelimit"
+)
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	// Instances is the number of Chrome processes to run
+	Instances int
+	// TabsPerInstance is the number of tabs kept open in each process
+	TabsPerInstance int
+	Headless        bool
+	UserAgent       string
+	// UserDataDir gets one sub folder per instance, empty means a temporary profile
+	UserDataDir string
+	// LeaseTimeout bounds how long Acquire waits for a free tab
+	LeaseTimeout time.Duration
+	// MaxLease is how long a tab may be held before it's reclaimed as leaked
+	MaxLease time.Duration
+	// MaxUses recycles a tab after this many leases, zero means never
+	MaxUses int
+	Limiter *ratelimit.Limiter
+}
+
+// Pool keeps Chrome tabs warm and leases them out for a scraping step.
+// Chrome is only started on the first lease, so deployments that only
+// scrape over plain HTTP never launch it.
+type Pool struct {
+	opts PoolOptions
+
+	mu        sync.Mutex
+	started   bool
+	closed    bool
+	instances []*instance
+	idle      chan *tab
+	leases    map[*tab]*PooledTab
+	// size counts open tabs and tabs being opened, it never exceeds the
+	// capacity of idle
+	size int
+
+	// restartMu serializes browser restarts, which run without mu held
+	restartMu sync.Mutex
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type PoolInterface interface {
+	Acquire(ctx context.Context, backend string) (Browser, error)
+	Close()
+}
+
+// instance is one Chrome process.
+type instance struct {
+	id          int
+	allocCtx    context.Context
+	cancelAlloc context.CancelFunc
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+type tab struct {
+	inst   *instance
+	cdp    *ChromeDP
+	cancel context.CancelFunc
+	uses   int
+}
+
+// PooledTab is a leased tab. Close hands it back to the pool.
+type PooledTab struct {
+	*ChromeDP
+	pool  *Pool
+	tab   *tab
+	since time.Time
+	once  sync.Once
+}
+
+func NewPool(opts PoolOptions) *Pool {
+	if opts.Instances <= 0 {
+		opts.Instances = 1
+	}
+	if opts.TabsPerInstance <= 0 {
+		opts.TabsPerInstance = 1
+	}
+
+	return &Pool{
+		opts:   opts,
+		idle:   make(chan *tab, opts.Instances*opts.TabsPerInstance),
+		leases: make(map[*tab]*PooledTab),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Acquire leases a browser for the given backend. Plain HTTP browsers are
+// cheap and created on demand, Chrome tabs come from the pool. The caller
+// must Close the browser once done with it.
+func (p *Pool) Acquire(ctx context.Context, backend string) (Browser, error) {
+	switch backend {
+	case "", BackendChrome:
+	case BackendHTTP:
+		return NewHTTPBrowser(p.opts.UserAgent, p.opts.Limiter), nil
+	default:
+		return nil, fmt.Errorf("unknown browser backend %q", backend)
+	}
+
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+
+	if p.opts.LeaseTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.LeaseTimeout)
+		defer cancel()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no browser tab available: %w", ctx.Err())
+		case <-p.stop:
+			return nil, fmt.Errorf("browser pool is closed")
+		case t := <-p.idle:
+			// The tab or its browser died while idle
+			if t.cdp.Ctx.Err() != nil {
+				go p.recycle(t)
+				continue
+			}
+
+			t.uses++
+			lease := &PooledTab{ChromeDP: t.cdp, pool: p, tab: t, since: time.Now()}
+			p.mu.Lock()
+			p.leases[t] = lease
+			p.mu.Unlock()
+			return lease, nil
+		}
+	}
+}
+
+// Close returns the tab to the pool. Calling it more than once, or after
+// the lease was reclaimed, does nothing.
+func (l *PooledTab) Close() {
+	l.once.Do(func() { l.pool.release(l) })
+}
+
+// Close shuts down every Chrome process. Leased tabs stop working.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	instances := p.instances
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for _, inst := range instances {
+		inst.close()
+	}
+	log.Println("Browser pool closed")
+}
+
+// start launches the instances and their tabs on first use.
+func (p *Pool) start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return fmt.Errorf("browser pool is closed")
+	}
+	if p.started {
+		return nil
+	}
+
+	for i := 0; i < p.opts.Instances; i++ {
+		inst, err := p.newInstance(i)
+		if err != nil {
+			for _, started := range p.instances {
+				started.close()
+			}
+			p.instances = nil
+			return err
+		}
+		p.instances = append(p.instances, inst)
+
+		for j := 0; j < p.opts.TabsPerInstance; j++ {
+			t, err := p.newTab(inst)
+			if err != nil {
+				log.Printf("Failed to open browser tab: %v", err)
+				continue
+			}
+			p.size++
+			p.idle <- t
+		}
+	}
+	if p.size == 0 {
+		return fmt.Errorf("failed to open any browser tab")
+	}
+
+	p.started = true
+	p.wg.Add(1)
+	go p.janitor()
+
+	log.Printf("Browser pool started with %d instances and %d tabs", len(p.instances), p.size)
+	return nil
+}
+
+func (p *Pool) newInstance(id int) (*instance, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", p.opts.Headless),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("disable-dev-shm-usage", true),
+	)
+	if p.opts.UserAgent != "" {
+		opts = append(opts, chromedp.UserAgent(p.opts.UserAgent))
+	}
+	if p.opts.UserDataDir != "" {
+		opts = append(opts, chromedp.UserDataDir(filepath.Join(p.opts.UserDataDir, fmt.Sprintf("instance-%d", id))))
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	ctx, cancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
+
+	// Start the browser process
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		cancelAlloc()
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+
+	return &instance{
+		id:          id,
+		allocCtx:    allocCtx,
+		cancelAlloc: cancelAlloc,
+		ctx:         ctx,
+		cancel:      cancel,
+	}, nil
+}
+
+func (inst *instance) close() {
+	inst.cancel()
+	inst.cancelAlloc()
+}
+
+func (p *Pool) newTab(inst *instance) (*tab, error) {
+	ctx, cancel := chromedp.NewContext(inst.ctx)
+	if err := chromedp.Run(ctx, chromedp.Navigate("about:blank")); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open tab: %w", err)
+	}
+
+	cdp := &ChromeDP{Limiter: p.opts.Limiter}
+	cdp.Ctx = ctx
+	cdp.trackNetwork()
+	return &tab{inst: inst, cdp: cdp, cancel: cancel}, nil
+}
+
+// release puts a returned tab back into the pool, recycling it first if
+// it crashed or reached MaxUses.
+func (p *Pool) release(lease *PooledTab) {
+	p.mu.Lock()
+	if p.leases[lease.tab] != lease {
+		// Already reclaimed by the janitor
+		p.mu.Unlock()
+		return
+	}
+	delete(p.leases, lease.tab)
+	closed := p.closed
+	p.mu.Unlock()
+
+	t := lease.tab
+	if closed {
+		t.cancel()
+		return
+	}
+	if (p.opts.MaxUses > 0 && t.uses >= p.opts.MaxUses) || !t.healthy() {
+		p.recycle(t)
+		return
+	}
+	p.idle <- t
+}
+
+// healthy checks that the tab still responds.
+func (t *tab) healthy() bool {
+	ctx, cancel := context.WithTimeout(t.cdp.Ctx, 5*time.Second)
+	defer cancel()
+
+	var result int
+	return chromedp.Run(ctx, chromedp.Evaluate(`1`, &result)) == nil
+}
+
+// recycle closes a tab and opens a replacement in its slot.
+func (p *Pool) recycle(t *tab) {
+	t.cancel()
+
+	p.mu.Lock()
+	closed := p.closed
+	if closed {
+		p.size--
+	}
+	p.mu.Unlock()
+
+	if !closed {
+		if err := p.replace(t.inst.id); err != nil {
+			log.Printf("Failed to replace browser tab: %v", err)
+		}
+	}
+}
+
+// replace opens a tab on instance id for a slot already counted in size,
+// restarting the browser if it crashed. The slot is given up on failure.
+func (p *Pool) replace(id int) error {
+	inst, err := p.liveInstance(id)
+	var t *tab
+	if err == nil {
+		t, err = p.newTab(inst)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		p.size--
+		return err
+	}
+	if p.closed {
+		p.size--
+		t.cancel()
+		return nil
+	}
+	p.idle <- t
+	return nil
+}
+
+// liveInstance returns instance id, restarting it if it stopped. Chrome
+// is started without holding mu, tabs and leases of the old instance keep
+// their pointer to it and are recycled once they fail.
+func (p *Pool) liveInstance(id int) (*instance, error) {
+	p.restartMu.Lock()
+	defer p.restartMu.Unlock()
+
+	p.mu.Lock()
+	inst, closed := p.instances[id], p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("browser pool is closed")
+	}
+	if inst.ctx.Err() == nil {
+		return inst, nil
+	}
+
+	log.Printf("Browser instance %d stopped, restarting it", id)
+	inst.close()
+	restarted, err := p.newInstance(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restart browser instance %d: %w", id, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		restarted.close()
+		return nil, fmt.Errorf("browser pool is closed")
+	}
+	p.instances[id] = restarted
+	return restarted, nil
+}
+
+// janitor reclaims leaked leases and refills tabs that could not be
+// replaced earlier.
+func (p *Pool) janitor() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.reclaim()
+			p.refill()
+		}
+	}
+}
+
+func (p *Pool) reclaim() {
+	if p.opts.MaxLease <= 0 {
+		return
+	}
+
+	var leaked []*tab
+	p.mu.Lock()
+	for t, lease := range p.leases {
+		if time.Since(lease.since) > p.opts.MaxLease {
+			log.Printf("Reclaiming browser tab leased for %s", time.Since(lease.since).Round(time.Second))
+			delete(p.leases, t)
+			leaked = append(leaked, t)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, t := range leaked {
+		p.recycle(t)
+	}
+}
+
+func (p *Pool) refill() {
+	// Reserve the missing slots so recycled tabs don't overfill the pool
+	p.mu.Lock()
+	missing := 0
+	if !p.closed {
+		missing = p.opts.Instances*p.opts.TabsPerInstance - p.size
+		p.size += missing
+	}
+	p.mu.Unlock()
+
+	for i := 0; i < missing; i++ {
+		if err := p.replace(i % p.opts.Instances); err != nil {
+			log.Printf("Failed to refill browser tab: %v", err)
+
+			p.mu.Lock()
+			p.size -= missing - i - 1
+			p.mu.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package browser
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// scrollPause lets lazy loaders react after each scroll step
+	scrollPause = 300 * time.Millisecond
+	// stableRounds is how many checks at the bottom of the page must see
+	// the same count before scrolling stops
+	stableRounds = 3
+)
+
+const scrollStepScript = `(() => {
+    window.scrollBy(0, Math.max(window.innerHeight, 400));
+    const bottom = Math.ceil(window.scrollY + window.innerHeight) >= document.documentElement.scrollHeight;
+    return bottom ? "bottom" : "more";
+})()`
+
+// AutoScroll scrolls through the page until the number of elements
+// matching selector with a value for field stops growing, so lazy loaded
+// images get their real URLs. It returns that number.
+func (cdp *ChromeDP) AutoScroll(selector string, field Field, timeout time.Duration) (int, error) {
+	if cdp.Ctx == nil {
+		return 0, fmt.Errorf("chromedp context is nil, call InitChromeDP first")
+	}
+
+	count := func() (int, error) {
+		rows, err := cdp.Extract(selector, map[string]Field{"value": field})
+		return countValues(rows), err
+	}
+
+	deadline := time.Now().Add(timeout)
+	last, stable := -1, 0
+	for stable < stableRounds {
+		if time.Now().After(deadline) {
+			log.Printf("Stopped scrolling after %s", timeout)
+			break
+		}
+
+		position, err := cdp.Evaluate(scrollStepScript)
+		if err != nil {
+			return 0, fmt.Errorf("failed to scroll: %w", err)
+		}
+
+		select {
+		case <-cdp.Ctx.Done():
+			return 0, cdp.Ctx.Err()
+		case <-time.After(scrollPause):
+		}
+
+		if position != "bottom" {
+			continue
+		}
+
+		// New content may extend the page, so keep going until the count
+		// holds steady at the bottom
+		found, err := count()
+		if err != nil {
+			return 0, err
+		}
+		if found == last {
+			stable++
+		} else {
+			last, stable = found, 0
+		}
+	}
+
+	return count()
+}
+
+// AutoScroll counts the matches right away, a fetched page loads nothing
+// lazily.
+func (h *HTTPBrowser) AutoScroll(selector string, field Field, timeout time.Duration) (int, error) {
+	rows, err := h.Extract(selector, map[string]Field{"value": field})
+	if err != nil {
+		return 0, err
+	}
+	return countValues(rows), nil
+}
+
+func countValues(rows []map[string]string) int {
+	found := 0
+	for _, row := range rows {
+		if row["value"] != "" {
+			found++
+		}
+	}
+	return found
+}
@@ -0,0 +1,130 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// pollInterval is how often conditions evaluated in the page are checked.
+const pollInterval = 200 * time.Millisecond
+
+// networkTracker follows the requests of a tab so WaitNetworkIdle can tell
+// when the page stopped loading.
+type networkTracker struct {
+	mu       sync.Mutex
+	inflight map[network.RequestID]bool
+	last     time.Time
+}
+
+// trackNetwork starts following the requests of the tab.
+func (cdp *ChromeDP) trackNetwork() {
+	tracker := &networkTracker{inflight: make(map[network.RequestID]bool), last: time.Now()}
+	chromedp.ListenTarget(cdp.Ctx, func(ev any) {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+
+		switch e := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			tracker.inflight[e.RequestID] = true
+		case *network.EventLoadingFinished:
+			delete(tracker.inflight, e.RequestID)
+		case *network.EventLoadingFailed:
+			delete(tracker.inflight, e.RequestID)
+		default:
+			return
+		}
+		tracker.last = time.Now()
+	})
+	cdp.network = tracker
+}
+
+// idleFor reports how long no request has been in flight.
+func (t *networkTracker) idleFor() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.inflight) > 0 {
+		return 0
+	}
+	return time.Since(t.last)
+}
+
+// WaitVisible waits until an element matching selector is visible.
+func (cdp *ChromeDP) WaitVisible(selector string, timeout time.Duration) error {
+	if cdp.Ctx == nil {
+		return fmt.Errorf("chromedp context is nil, call InitChromeDP first")
+	}
+
+	ctx, cancel := context.WithTimeout(cdp.Ctx, timeout)
+	defer cancel()
+
+	if err := chromedp.Run(ctx, chromedp.WaitVisible(selector, chromedp.ByQuery)); err != nil {
+		return fmt.Errorf("waiting for %q to be visible: %w", selector, err)
+	}
+	return nil
+}
+
+// WaitCount waits until at least count elements match selector.
+func (cdp *ChromeDP) WaitCount(selector string, count int, timeout time.Duration) error {
+	if cdp.Ctx == nil {
+		return fmt.Errorf("chromedp context is nil, call InitChromeDP first")
+	}
+
+	quoted, err := json.Marshal(selector)
+	if err != nil {
+		return err
+	}
+	expression := fmt.Sprintf(`String(document.querySelectorAll(%s).length)`, quoted)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		result, err := cdp.Evaluate(expression)
+		if err != nil {
+			return err
+		}
+		found, _ := strconv.Atoi(result)
+		if found >= count {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("waiting for %d matches of %q: found %d after %s", count, selector, found, timeout)
+		}
+
+		select {
+		case <-cdp.Ctx.Done():
+			return cdp.Ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// WaitNetworkIdle waits until no request has been in flight for idle.
+func (cdp *ChromeDP) WaitNetworkIdle(idle, timeout time.Duration) error {
+	if cdp.Ctx == nil {
+		return fmt.Errorf("chromedp context is nil, call InitChromeDP first")
+	}
+	if cdp.network == nil {
+		return fmt.Errorf("network tracking is not enabled for this tab")
+	}
+
+	deadline := time.Now().Add(timeout)
+	for cdp.network.idleFor() < idle {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("network still busy after %s", timeout)
+		}
+
+		select {
+		case <-cdp.Ctx.Done():
+			return cdp.Ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+	return nil
+}
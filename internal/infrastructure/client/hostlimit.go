@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// HostLimiter caps the number of requests in flight per host. One
+// limiter is shared by every download so concurrent jobs against the
+// same site stay within the same budget.
+type HostLimiter struct {
+	limit int
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewHostLimiter allows up to limit concurrent requests per host. A limit
+// below one means one request at a time.
+func NewHostLimiter(limit int) *HostLimiter {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &HostLimiter{
+		limit: limit,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+func (h *HostLimiter) hostSlots(host string) chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	slots, ok := h.slots[host]
+	if !ok {
+		slots = make(chan struct{}, h.limit)
+		h.slots[host] = slots
+	}
+	return slots
+}
+
+// Acquire blocks until a slot for the host of rawURL is free or ctx is
+// done. The returned func releases the slot.
+func (h *HostLimiter) Acquire(ctx context.Context, rawURL string) (func(), error) {
+	if h == nil {
+		return func() {}, nil
+	}
+
+	host := rawURL
+	if parsed, err := url.Parse(rawURL); err == nil && parsed.Host != "" {
+		host = normalizeHost(parsed.Hostname())
+	}
+
+	slots := h.hostSlots(host)
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxBackoff = time.Minute
+
+// HTTPError is returned for an unexpected response status.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP error: %s", e.Status)
+}
+
+// PermanentError marks a failure that retrying won't fix.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// IsRetryable reports whether a failed fetch is worth trying again.
+// Timeouts, network failures, 408, 429 and 5xx responses are; other
+// statuses, cancellation and local errors are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch {
+		case httpErr.StatusCode == http.StatusRequestTimeout,
+			httpErr.StatusCode == http.StatusTooManyRequests,
+			httpErr.StatusCode >= 500:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or
+// as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoff returns the delay before the given retry attempt, doubling from
+// base with full jitter.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = 500 * time.Millisecond
+	}
+	delay := base << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// fetchWithRetry downloads url into tempPath, retrying retryable errors
+// up to RetryCount times. A host slot is only held while a request is in
+// flight so waiting retries don't block other downloads.
+func (md *MangaDownloader) fetchWithRetry(ctx context.Context, url, tempPath string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		release, err := md.config.Hosts.Acquire(ctx, url)
+		if err != nil {
+			return "", err
+		}
+		ext, err := md.downloadAndDetermineExtension(ctx, url, tempPath)
+		release()
+		if err == nil {
+			return ext, nil
+		}
+
+		if !IsRetryable(err) || attempt >= md.config.RetryCount {
+			return "", err
+		}
+
+		delay := backoff(md.config.RetryDelay, attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > delay {
+			delay = min(httpErr.RetryAfter, maxBackoff)
+		}
+
+		log.Printf("Retrying %s in %s (attempt %d/%d): %v", url, delay.Round(time.Millisecond), attempt+1, md.config.RetryCount, err)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
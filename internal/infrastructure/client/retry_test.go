@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{fmt.Errorf("fetch: %w", context.Canceled), false},
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{&HTTPError{StatusCode: http.StatusRequestTimeout}, true},
+		{&HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{fmt.Errorf("fetch: %w", &HTTPError{StatusCode: http.StatusBadGateway}), true},
+		{&HTTPError{StatusCode: http.StatusNotFound}, false},
+		{&HTTPError{StatusCode: http.StatusForbidden}, false},
+		{&PermanentError{Err: &HTTPError{StatusCode: http.StatusServiceUnavailable}}, false},
+		{errors.New("unsupported image type"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("parseRetryAfter(120) = %v, want 2m", got)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 58*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%q) = %v, want about an hour", date, got)
+	}
+
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	for _, value := range []string{"", "0", "-5", "soon", past} {
+		if got := parseRetryAfter(value); got != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, want 0", value, got)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	limits := map[int]time.Duration{
+		0:  time.Second,
+		3:  8 * time.Second,
+		10: maxBackoff,
+		// Shifting this far overflows, which must not produce a negative delay
+		62: maxBackoff,
+	}
+	for attempt, limit := range limits {
+		for i := 0; i < 100; i++ {
+			if got := backoff(time.Second, attempt); got < limit/2 || got > limit {
+				t.Fatalf("backoff(1s, %d) = %v, want within [%v, %v]", attempt, got, limit/2, limit)
+			}
+		}
+	}
+
+	if got := backoff(0, 0); got > 500*time.Millisecond {
+		t.Errorf("backoff(0, 0) = %v, want the 500ms default base", got)
+	}
+}
@@ -3,18 +3,19 @@ package client
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/sucumbap/mangaroo/internal/core"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/blob"
 	"github.com/sucumbap/mangaroo/internal/infrastructure/browser"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/ratelimit"
 	"github.com/sucumbap/mangaroo/internal/infrastructure/storage"
 	"github.com/sucumbap/mangaroo/internal/utils"
 )
@@ -23,27 +24,67 @@ type Config struct {
 	BaseURL      string
 	OutputFolder string
 	UserAgent    string
+	// PageWorkers is the number of images fetched concurrently per chapter
+	PageWorkers int
+	// ChapterWorkers is the number of chapters downloaded concurrently
+	ChapterWorkers int
+	// Hosts caps concurrent image requests per host across downloads
+	Hosts *HostLimiter
+	// Limiter throttles image requests and navigations per host
+	Limiter *ratelimit.Limiter
+	// RetryCount is how often a failed image fetch is retried
+	RetryCount int
+	// RetryDelay is the base delay of the exponential backoff
+	RetryDelay time.Duration
+	// Browsers leases browser tabs, a private single tab pool is used if nil
+	Browsers *browser.Pool
+	// Blobs stores the page images referenced by the index
+	Blobs blob.Store
+	// BulkMaxDocs and BulkMaxBytes bound each _bulk request of page documents
+	BulkMaxDocs  int
+	BulkMaxBytes int
 }
 
+// EventFunc receives the progress events emitted while downloading.
+type EventFunc func(event core.ProgressEvent)
+
 type MangaDownloader struct {
-	config    Config
-	ctx       context.Context
-	cancelCtx context.CancelFunc
-	elastic   *storage.ElasticClient
-	mangaID   string
-	browserDP browser.ChromeDPInterface
+	config   Config
+	elastic  *storage.ElasticClient
+	mangaID  string
+	source   Source
+	browsers *browser.Pool
+	ownPool  bool
+	onEvent  EventFunc
+	chapters []core.Chapter
+	// series caches the series page so it's only loaded once per download
+	seriesMu  sync.Mutex
+	series    *core.Manga
+	skip      map[string]bool
+	completed map[string]bool
+	summary   core.DownloadSummary
+
+	// mu guards the summary and chapter counters
+	mu             sync.Mutex
+	chaptersDone   int
+	chaptersFailed int
+	chaptersTotal  int
 }
 type MangaDownloaderInterface interface {
 	GetMangaStatus() (string, error)
 	GetMangaTitle() (string, error)
-	downloadChapter(chapterNum int) error
-	downloadAndDetermineExtension(url, tempPath string) (string, error)
-	normalizeImageURL(url string) string
-	getChapterImageURLs(chapterURL string) ([]string, error)
-	getTotalChapters() (int, error)
-	Run() error
+	Series(ctx context.Context) (core.Manga, error)
+	FetchMetadata(ctx context.Context) (core.Manga, error)
+	Chapters() []core.Chapter
+	SetSkipChapters(chapters map[string]bool)
+	Resume(checkpoint core.Checkpoint)
+	Summary() core.DownloadSummary
+	downloadChapter(ctx context.Context, chapter core.Chapter) error
+	downloadAndDetermineExtension(ctx context.Context, url, tempPath string) (string, error)
+	Run(ctx context.Context) error
 	Close()
 	SetElasticClient(elasticClient *storage.ElasticClient)
+	SetEventFunc(fn EventFunc)
 }
 
 func (md *MangaDownloader) SetElasticClient(elasticClient *storage.ElasticClient) {
@@ -53,162 +94,351 @@ func (md *MangaDownloader) SetElasticClient(elasticClient *storage.ElasticClient
 	}
 }
 
-func NewMangaDownloader(config Config, mangaID string) (*MangaDownloader, error) {
-	// Initialize ChromeDP context
-	var browserDP browser.ChromeDPInterface = &browser.ChromeDP{}
-	chromeDPctx, err := browserDP.InitChromeDP()
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize ChromeDP: %w", err)
+func (md *MangaDownloader) SetEventFunc(fn EventFunc) {
+	md.onEvent = fn
+}
+
+// emit stamps the event with the current chapter counters and passes it
+// to the registered EventFunc.
+func (md *MangaDownloader) emit(event core.ProgressEvent) {
+	if md.onEvent == nil {
+		return
+	}
+	md.mu.Lock()
+	event.ChaptersDone = md.chaptersDone
+	event.ChaptersFailed = md.chaptersFailed
+	event.ChaptersTotal = md.chaptersTotal
+	md.mu.Unlock()
+	event.Time = time.Now().UTC()
+	md.onEvent(event)
+}
+
+func NewMangaDownloader(config Config, mangaID string, source Source) (*MangaDownloader, error) {
+	if source == nil {
+		return nil, fmt.Errorf("no source given for %s", config.BaseURL)
+	}
+	if config.Blobs == nil {
+		return nil, fmt.Errorf("no blob store given for %s", config.BaseURL)
+	}
+
+	browsers, ownPool := config.Browsers, false
+	if browsers == nil {
+		browsers = browser.NewPool(browser.PoolOptions{
+			Headless:  true,
+			UserAgent: config.UserAgent,
+			Limiter:   config.Limiter,
+		})
+		ownPool = true
 	}
 
 	return &MangaDownloader{
-		config:    config,
-		ctx:       chromeDPctx.Ctx,
-		cancelCtx: func() { chromeDPctx.CancelCtx(); chromeDPctx.CancelAlloc() },
-		elastic:   nil,
-		mangaID:   mangaID,
-		browserDP: browserDP,
+		config:   config,
+		elastic:  nil,
+		mangaID:  mangaID,
+		summary:  core.DownloadSummary{Added: []string{}, Failed: []string{}},
+		source:   source,
+		browsers: browsers,
+		ownPool:  ownPool,
 	}, nil
 }
 
+// withBrowser leases a browser for the backend of the source, holding it
+// only for the duration of fn.
+func (md *MangaDownloader) withBrowser(ctx context.Context, fn func(b browser.Browser) error) error {
+	b, err := md.browsers.Acquire(ctx, md.source.Backend())
+	if err != nil {
+		return fmt.Errorf("failed to get a browser: %w", err)
+	}
+	defer b.Close()
+	return fn(b)
+}
+
+// Series returns the series snapshot: metadata and chapter list scraped
+// in one navigation. The first successful fetch is cached for the rest of
+// the download.
+func (md *MangaDownloader) Series(ctx context.Context) (core.Manga, error) {
+	md.seriesMu.Lock()
+	defer md.seriesMu.Unlock()
+
+	if md.series != nil {
+		return *md.series, nil
+	}
+
+	var manga core.Manga
+	err := md.withBrowser(ctx, func(b browser.Browser) error {
+		var err error
+		manga, err = md.source.FetchSeries(b, md.config.BaseURL)
+		return err
+	})
+	if err != nil {
+		return manga, err
+	}
+
+	md.series = &manga
+	return manga, nil
+}
+
 func (md *MangaDownloader) Close() {
 	if md == nil {
 		return
 	}
 
-	if md.browserDP != nil {
+	// A shared pool outlives the download
+	if md.ownPool {
 		func() {
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("Recovered from panic when closing browserDP: %v", r)
+					log.Printf("Recovered from panic when closing browser pool: %v", r)
 				}
 			}()
-			md.browserDP.CloseChromeDP()
+			md.browsers.Close()
 		}()
 	}
 }
 
-func (md *MangaDownloader) Run() error {
+// Run downloads every chapter of the series using up to ChapterWorkers
+// chapters at a time. It stops once ctx is cancelled.
+func (md *MangaDownloader) Run(ctx context.Context) error {
 	// Create output folder
 	if err := os.MkdirAll(md.config.OutputFolder, 0755); err != nil {
 		return fmt.Errorf("error creating output folder: %w", err)
 	}
-	// Get manga status first
-	status, err := md.GetMangaStatus()
+	// Status and chapter list come from the same snapshot
+	series, err := md.Series(ctx)
 	if err != nil {
-		log.Printf("Warning: Could not get manga status: %v", err)
-		status = "Unknown"
+		return fmt.Errorf("failed to get chapter list: %w", err)
 	}
-	fmt.Printf("Manga Status: %s\n", status)
+	log.Printf("Manga status: %s", series.Status)
+	chapters := series.Chapters
+	md.chapters = chapters
 
-	// Get total chapters
-	totalChapters, err := md.getTotalChapters()
-	if err != nil {
-		return fmt.Errorf("failed to get chapter count: %w", err)
+	// Leave out chapters that are already downloaded
+	pending := make([]core.Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		if md.completed[chapter.Number] {
+			continue
+		}
+		if md.skip[chapter.Number] {
+			md.summary.Skipped++
+			continue
+		}
+		pending = append(pending, chapter)
 	}
+	totalChapters := len(pending)
+
+	log.Printf("Found %d chapters, %d to download", len(chapters), totalChapters)
 
-	fmt.Printf("Found %d chapters\n", totalChapters)
+	md.mu.Lock()
+	md.chaptersTotal = totalChapters
+	md.mu.Unlock()
+	md.emit(core.ProgressEvent{Type: core.EventJobStarted})
 
-	// Download each chapter
-	for i := 1; i <= totalChapters; i++ {
-		if err := md.downloadChapter(i); err != nil {
-			log.Printf("Error downloading chapter %d: %v", i, err)
+	workers := md.config.ChapterWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	queue := make(chan core.Chapter)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chapter := range queue {
+				md.processChapter(ctx, chapter)
+			}
+		}()
+	}
+
+feed:
+	for _, chapter := range pending {
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- chapter:
 		}
-		time.Sleep(3 * time.Second) // Be polite to the server
 	}
+	close(queue)
+	wg.Wait()
 
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("download cancelled: %w", err)
+	}
 	return nil
 }
 
-func (md *MangaDownloader) getTotalChapters() (int, error) {
-	var count int
+// processChapter downloads one chapter and records the outcome.
+func (md *MangaDownloader) processChapter(ctx context.Context, chapter core.Chapter) {
+	md.emit(core.ProgressEvent{Type: core.EventChapterStarted, Chapter: chapter.Number})
 
-	if err := md.browserDP.Navigate(md.config.BaseURL); err != nil {
-		return 0, fmt.Errorf("failed to navigate to URL: %w", err)
-	}
-	// Sleep for 2 seconds
-	md.browserDP.Bsleep(2)
-	// Evaluate the JavaScript to get the total chapter count
-	result, err := md.browserDP.Evaluate(`document.querySelectorAll('div.chapters table.uk-table tbody tr').length`)
+	err := md.downloadChapter(ctx, chapter)
+
+	md.mu.Lock()
 	if err != nil {
-		return 0, fmt.Errorf("failed to evaluate JavaScript: %w", err)
+		md.chaptersFailed++
+		md.summary.Failed = append(md.summary.Failed, chapter.Number)
+	} else {
+		md.chaptersDone++
+		md.summary.Added = append(md.summary.Added, chapter.Number)
 	}
-	count, err = strconv.Atoi(result)
+	md.mu.Unlock()
+
 	if err != nil {
-		return 0, fmt.Errorf("failed to convert result to integer: %w", err)
+		log.Printf("Error downloading chapter %s: %v", chapter.Number, err)
+		md.emit(core.ProgressEvent{Type: core.EventChapterFailed, Chapter: chapter.Number, Error: err.Error()})
+		return
 	}
-
-	return count, nil
+	md.emit(core.ProgressEvent{Type: core.EventChapterFinished, Chapter: chapter.Number})
 }
 
-func (md *MangaDownloader) downloadChapter(chapterNum int) error {
-	chapterURL := fmt.Sprintf("%s/c%d", md.config.BaseURL, chapterNum)
-	chapterFolder := filepath.Join(md.config.OutputFolder, fmt.Sprintf("c%d", chapterNum))
+func (md *MangaDownloader) downloadChapter(ctx context.Context, chapter core.Chapter) error {
+	// Keep pages per series, the job manager runs one job per series at a
+	// time so chapter folders are never shared
+	chapterFolder := filepath.Join(md.config.OutputFolder, filepath.Base(md.mangaID), "c"+chapter.Number)
 
 	if err := os.MkdirAll(chapterFolder, 0755); err != nil {
-		return fmt.Errorf("error creating folder for chapter %d: %w", chapterNum, err)
+		return fmt.Errorf("error creating folder for chapter %s: %w", chapter.Number, err)
 	}
 
-	imageURLs, err := md.getChapterImageURLs(chapterURL)
+	var imageURLs []string
+	err := md.withBrowser(ctx, func(b browser.Browser) error {
+		var err error
+		imageURLs, err = md.source.ListPages(b, chapter.URL)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to get image URLs: %w", err)
 	}
 
-	// Get manga title
-	mangaTitle, err := md.GetMangaTitle()
-	if err != nil {
-		log.Printf("Warning: Could not get manga title: %v", err)
-		mangaTitle = "unknown"
+	// The title comes from the cached snapshot, this doesn't navigate
+	mangaTitle := "unknown"
+	if series, err := md.Series(ctx); err == nil {
+		mangaTitle = series.Title
 	}
 
-	// Download all images first
-	var downloadedImages []string
-	for i, imgURL := range imageURLs {
-		absURL := md.normalizeImageURL(imgURL)
+	// Download all images first, keeping them in page order
+	paths := make([]string, len(imageURLs))
+	pages := make(chan int)
+	workers := md.config.PageWorkers
+	if workers <= 0 {
+		workers = 1
+	}
 
-		// First download to determine the file type
-		tempPath := filepath.Join(chapterFolder, fmt.Sprintf("%03d_temp", i+1))
-		ext, err := md.downloadAndDetermineExtension(absURL, tempPath)
-		if err != nil {
-			log.Printf("Error downloading image %d: %v", i+1, err)
-			continue
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pages {
+				path, err := md.downloadPage(ctx, chapterFolder, chapter, i+1, imageURLs[i], len(imageURLs))
+				if err != nil {
+					md.pageFailed(ctx, chapter, i+1, imageURLs[i], len(imageURLs), err)
+					continue
+				}
+				paths[i] = path
+			}
+		}()
+	}
+
+feed:
+	for i := range imageURLs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case pages <- i:
 		}
+	}
+	close(pages)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("chapter %s interrupted: %w", chapter.Number, err)
+	}
 
-		// Now create the final file with correct extension
-		finalPath := filepath.Join(chapterFolder, fmt.Sprintf("%03d.%s", i+1, ext))
-		if err := os.Rename(tempPath, finalPath); err != nil {
-			log.Printf("Error renaming temp file for image %d: %v", i+1, err)
+	// Leave the chapter unindexed so a later run fetches the missing pages,
+	// the ones already on disk are reused
+	var downloadedImages []string
+	missing := 0
+	for _, path := range paths {
+		if path == "" {
+			missing++
 			continue
 		}
-
-		downloadedImages = append(downloadedImages, finalPath)
-		time.Sleep(500 * time.Millisecond)
+		downloadedImages = append(downloadedImages, path)
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d of %d pages could not be downloaded", missing, len(paths))
 	}
 
 	// Upload to Elasticsearch
 	if md.elastic != nil {
-		indexName := md.elastic.GetMangaIndexName(mangaTitle, md.mangaID)
+		indexName := md.elastic.GetMangaIndexName(md.source.Name(), md.mangaID)
+		bulk := md.elastic.NewBulkIndexer(storage.BulkOptions{
+			MaxDocs:    md.config.BulkMaxDocs,
+			MaxBytes:   md.config.BulkMaxBytes,
+			Retries:    md.config.RetryCount,
+			RetryDelay: md.config.RetryDelay,
+		})
+
+		// Callbacks run from AddMangaImage and Flush on this goroutine
+		failed := 0
 		for i, imgPath := range downloadedImages {
 			metadata := map[string]interface{}{
-				"manga_url":   md.config.BaseURL,
-				"manga_title": mangaTitle,
-				"manga_id":    md.mangaID,
-				"chapter_num": chapterNum,
-				"image_index": i + 1,
+				"manga_url":     md.config.BaseURL,
+				"manga_title":   mangaTitle,
+				"manga_id":      md.mangaID,
+				"chapter_num":   chapter.Number,
+				"chapter_title": chapter.Title,
+				"chapter_url":   chapter.URL,
+				"image_index":   i + 1,
+				"page_count":    len(downloadedImages),
 			}
 
-			if err := md.elastic.IndexMangaImage(indexName, chapterNum, i+1, imgPath, metadata); err != nil {
-				log.Printf("Failed to upload image %d to Elasticsearch: %v", i+1, err)
-				continue // Skip deletion if upload failed
+			pageNum, imgURL, path := i+1, imageURLs[i], imgPath
+			image, existed, err := md.config.Blobs.Put(ctx, path)
+			if err != nil {
+				failed++
+				md.pageFailed(ctx, chapter, pageNum, imgURL, len(downloadedImages), fmt.Errorf("failed to store image: %w", err))
+				continue
+			}
+			if existed {
+				log.Printf("Image %d of chapter %s is already stored as %s", pageNum, chapter.Number, image.Hash)
 			}
 
-			// Only delete if upload succeeded
-			if err := os.Remove(imgPath); err != nil {
-				log.Printf("Failed to delete image %s: %v", imgPath, err)
+			err = bulk.AddMangaImage(ctx, indexName, chapter.Number, pageNum, image, metadata, func(err error) {
+				if err != nil {
+					// Keep the file so a later run indexes it without refetching
+					failed++
+					md.pageFailed(ctx, chapter, pageNum, imgURL, len(downloadedImages), err)
+					return
+				}
+				md.emit(core.ProgressEvent{
+					Type:       core.EventPageIndexed,
+					Chapter:    chapter.Number,
+					Page:       pageNum,
+					TotalPages: len(downloadedImages),
+				})
+				if err := os.Remove(path); err != nil {
+					log.Printf("Failed to delete image %s: %v", path, err)
+				}
+			})
+			if err != nil {
+				failed++
+				md.pageFailed(ctx, chapter, pageNum, imgURL, len(downloadedImages), err)
 			}
 		}
 
-		// Delete chapter folder after all images are processed
+		if err := bulk.Flush(ctx); err != nil {
+			log.Printf("Bulk indexing of chapter %s failed: %v", chapter.Number, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("chapter %s interrupted: %w", chapter.Number, err)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d pages could not be indexed", failed, len(downloadedImages))
+		}
+
+		// Delete chapter folder after all images are indexed
 		if err := os.RemoveAll(chapterFolder); err != nil {
 			log.Printf("Failed to delete chapter folder %s: %v", chapterFolder, err)
 		}
@@ -216,84 +446,184 @@ func (md *MangaDownloader) downloadChapter(chapterNum int) error {
 
 	return nil
 }
-func (md *MangaDownloader) getChapterImageURLs(chapterURL string) ([]string, error) {
-	// Navigate to the chapter URL
-	if err := md.browserDP.Navigate(chapterURL); err != nil {
-		return nil, fmt.Errorf("failed to navigate to chapter URL: %w", err)
-	}
-
-	// Sleep for 3 seconds to allow the page to load
-	md.browserDP.Bsleep(3)
-
-	// Evaluate JavaScript to extract image URLs
-	result, err := md.browserDP.Evaluate(`
-        JSON.stringify(
-            Array.from(document.querySelectorAll('div#imgs img')).map(img => {
-                return img.getAttribute('data-src') || img.getAttribute('src');
-            }).filter(url => url && !url.startsWith('data:'))
-        )
-    `)
+
+// downloadPage fetches a single page into the chapter folder and returns
+// its path.
+func (md *MangaDownloader) downloadPage(ctx context.Context, chapterFolder string, chapter core.Chapter, pageNum int, imgURL string, totalPages int) (string, error) {
+	// Reuse pages left over from an interrupted run
+	if finalPath, ok := existingPage(chapterFolder, pageNum); ok {
+		return finalPath, nil
+	}
+
+	// First download to determine the file type
+	absURL := md.source.NormalizeImageURL(imgURL)
+	tempPath := filepath.Join(chapterFolder, fmt.Sprintf("%03d_temp", pageNum))
+	ext, err := md.fetchWithRetry(ctx, absURL, tempPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to evaluate JavaScript for image URLs: %w", err)
+		os.Remove(tempPath)
+		return "", err
 	}
 
-	// Parse the JSON string into a slice of strings
-	var imageURLs []string
-	if err := json.Unmarshal([]byte(result), &imageURLs); err != nil {
-		return nil, fmt.Errorf("failed to parse image URLs: %w", err)
+	// Now create the final file with correct extension
+	finalPath := filepath.Join(chapterFolder, fmt.Sprintf("%03d.%s", pageNum, ext))
+	if err := os.Rename(tempPath, finalPath); err != nil {
+		return "", fmt.Errorf("error renaming temp file: %w", err)
 	}
 
-	return imageURLs, nil
+	md.emit(core.ProgressEvent{
+		Type:       core.EventPageDownloaded,
+		Chapter:    chapter.Number,
+		Page:       pageNum,
+		TotalPages: totalPages,
+	})
+	return finalPath, nil
 }
 
-func (md *MangaDownloader) normalizeImageURL(url string) string {
-	if strings.HasPrefix(url, "http") {
-		return url
+// pageFailed records a page that could not be downloaded or indexed.
+func (md *MangaDownloader) pageFailed(ctx context.Context, chapter core.Chapter, pageNum int, imgURL string, totalPages int, err error) {
+	// Pages cut short by cancellation are fetched again on resume
+	if ctx.Err() != nil {
+		return
 	}
 
-	if strings.HasPrefix(url, "//") {
-		return "https:" + url
+	log.Printf("Error processing image %d of chapter %s: %v", pageNum, chapter.Number, err)
+	md.mu.Lock()
+	md.summary.FailedPages = append(md.summary.FailedPages, core.FailedPage{
+		Chapter: chapter.Number,
+		Page:    pageNum,
+		URL:     imgURL,
+		Error:   err.Error(),
+	})
+	md.mu.Unlock()
+
+	md.emit(core.ProgressEvent{
+		Type:       core.EventPageFailed,
+		Chapter:    chapter.Number,
+		Page:       pageNum,
+		TotalPages: totalPages,
+		Error:      err.Error(),
+	})
+}
+
+// existingPage finds a page already downloaded into the chapter folder.
+func existingPage(chapterFolder string, pageNum int) (string, bool) {
+	matches, err := filepath.Glob(filepath.Join(chapterFolder, fmt.Sprintf("%03d.*", pageNum)))
+	if err != nil || len(matches) == 0 {
+		return "", false
 	}
+	return matches[0], true
+}
 
-	if !strings.HasPrefix(url, "/") {
-		return "https://" + url
+// Resume makes Run continue from a checkpoint of an interrupted run.
+// Completed chapters are left out and still reported as added.
+func (md *MangaDownloader) Resume(checkpoint core.Checkpoint) {
+	md.completed = make(map[string]bool, len(checkpoint.CompletedChapters))
+	for _, number := range checkpoint.CompletedChapters {
+		md.completed[number] = true
+		md.summary.Added = append(md.summary.Added, number)
 	}
+}
 
-	return "https://mangakatana.com" + url
+// SetSkipChapters makes Run leave out the given chapter numbers.
+func (md *MangaDownloader) SetSkipChapters(chapters map[string]bool) {
+	md.skip = chapters
 }
 
-func (md *MangaDownloader) GetMangaStatus() (string, error) {
-	// Navigate to the base URL
-	if err := md.browserDP.Navigate(md.config.BaseURL); err != nil {
-		return "", fmt.Errorf("failed to navigate to base URL: %w", err)
-	}
-
-	// Sleep for 2 seconds to allow the page to load
-	md.browserDP.Bsleep(2)
-
-	// Evaluate JavaScript to extract the manga status
-	result, err := md.browserDP.Evaluate(`
-        // Find the status element by its specific class structure
-        const statusElement = document.querySelector('li.d-row-small div.status');
-        
-        // Return the status text if found, otherwise "Unknown"
-        statusElement ? statusElement.textContent.trim() : "Unknown"
-    `)
+// Summary reports the chapters added, failed and skipped by Run.
+func (md *MangaDownloader) Summary() core.DownloadSummary {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	return md.summary
+}
+
+// Chapters returns the chapter list scraped by the last Run.
+func (md *MangaDownloader) Chapters() []core.Chapter {
+	return md.chapters
+}
+
+// FetchMetadata scrapes the series metadata and stores its cover in the
+// blob store.
+func (md *MangaDownloader) FetchMetadata(ctx context.Context) (core.Manga, error) {
+	manga, err := md.Series(ctx)
+	if err != nil {
+		return manga, fmt.Errorf("failed to fetch series metadata: %w", err)
+	}
+	manga.ID = md.mangaID
+
+	if manga.CoverURL != "" {
+		coverHash, thumbHash, err := md.downloadCover(ctx, manga.CoverURL)
+		if err != nil {
+			log.Printf("Warning: Could not download cover: %v", err)
+		} else {
+			manga.CoverHash = coverHash
+			manga.CoverThumbHash = thumbHash
+		}
+	}
+
+	return manga, nil
+}
+
+// coverThumbWidth is the width of cover thumbnails in pixels
+const coverThumbWidth = 240
+
+// downloadCover stores the cover and a thumbnail of it in the blob store
+// and returns both hashes. The thumbnail hash is empty if it couldn't be
+// made.
+func (md *MangaDownloader) downloadCover(ctx context.Context, coverURL string) (string, string, error) {
+	coverFolder := filepath.Join(md.config.OutputFolder, "covers")
+	if err := os.MkdirAll(coverFolder, 0755); err != nil {
+		return "", "", fmt.Errorf("error creating cover folder: %w", err)
+	}
+
+	name := filepath.Base(md.mangaID)
+	tempPath := filepath.Join(coverFolder, name+"_temp")
+	ext, err := md.fetchWithRetry(ctx, coverURL, tempPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to evaluate JavaScript for manga status: %w", err)
+		os.Remove(tempPath)
+		return "", "", err
 	}
 
-	// Clean up the status text
-	status := strings.TrimSpace(result)
-	status = strings.ReplaceAll(status, "status", "")
-	status = strings.TrimSpace(status)
+	finalPath := filepath.Join(coverFolder, fmt.Sprintf("%s.%s", name, ext))
+	if err := os.Rename(tempPath, finalPath); err != nil {
+		return "", "", fmt.Errorf("error renaming temp cover file: %w", err)
+	}
+	defer os.Remove(finalPath)
+
+	cover, _, err := md.config.Blobs.Put(ctx, finalPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to store cover: %w", err)
+	}
 
-	return status, nil
+	thumbPath := filepath.Join(coverFolder, name+"_thumb.jpg")
+	defer os.Remove(thumbPath)
+	if err := blob.Thumbnail(finalPath, thumbPath, coverThumbWidth); err != nil {
+		log.Printf("Warning: Could not create cover thumbnail: %v", err)
+		return cover.Hash, "", nil
+	}
+	thumb, _, err := md.config.Blobs.Put(ctx, thumbPath)
+	if err != nil {
+		log.Printf("Warning: Could not store cover thumbnail: %v", err)
+		return cover.Hash, "", nil
+	}
+	return cover.Hash, thumb.Hash, nil
 }
 
-func (md *MangaDownloader) downloadAndDetermineExtension(url, tempPath string) (string, error) {
+func (md *MangaDownloader) GetMangaStatus() (string, error) {
+	manga, err := md.Series(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return manga.Status, nil
+}
+
+func (md *MangaDownloader) downloadAndDetermineExtension(ctx context.Context, url, tempPath string) (string, error) {
+	// Wait for our turn so concurrent jobs stay polite to the host
+	if err := md.config.Limiter.Wait(ctx, url); err != nil {
+		return "", fmt.Errorf("rate limiter: %w", err)
+	}
+
 	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -308,13 +638,17 @@ func (md *MangaDownloader) downloadAndDetermineExtension(url, tempPath string) (
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP error: %s", resp.Status)
+		httpErr := &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			httpErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return "", httpErr
 	}
 
 	// Create temp file
 	file, err := os.Create(tempPath)
 	if err != nil {
-		return "", err
+		return "", &PermanentError{Err: err}
 	}
 	defer file.Close()
 
@@ -342,39 +676,12 @@ func (md *MangaDownloader) downloadAndDetermineExtension(url, tempPath string) (
 }
 
 func (md *MangaDownloader) GetMangaTitle() (string, error) {
-	if md.browserDP == nil {
-		return "unknown", fmt.Errorf("browserDP is not initialized")
-	}
-
-	// Navigate to the base URL
-	log.Printf("Getting manga title for: %s", md.config.BaseURL)
-	if err := md.browserDP.Navigate(md.config.BaseURL); err != nil {
-		log.Printf("Failed to navigate to base URL: %v", err)
-		return "unknown", fmt.Errorf("failed to navigate to base URL: %w", err)
-	}
-
-	// Sleep for 2 seconds to allow the page to load
-	log.Println("Waiting for page to load...")
-	if err := md.browserDP.Bsleep(2); err != nil {
-		log.Printf("Sleep failed: %v", err)
-		return "unknown", err
-	}
-
-	// Evaluate JavaScript to extract the manga title
-	log.Println("Evaluating JavaScript to extract title...")
-	result, err := md.browserDP.Evaluate(`
-        // Try multiple selectors
-        document.querySelector('h1.heading')?.textContent.trim() || 
-        document.querySelector('h1.title')?.textContent.trim() || 
-        document.querySelector('div.manga-info h1')?.textContent.trim() || 
-        document.title.split('|')[0].trim() || 
-        "unknown"
-    `)
+	manga, err := md.Series(context.Background())
 	if err != nil {
-		log.Printf("Failed to evaluate JavaScript for manga title: %v", err)
-		return "unknown", fmt.Errorf("failed to evaluate JavaScript for manga title: %w", err)
+		log.Printf("Failed to get manga title: %v", err)
+		return "unknown", err
 	}
 
-	log.Printf("Found manga title: %s", result)
-	return strings.TrimSpace(result), nil
+	log.Printf("Found manga title: %s", manga.Title)
+	return manga.Title, nil
 }
@@ -1,8 +1,10 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/sucumbap/mangaroo/internal/core"
 )
@@ -19,42 +21,28 @@ func NewScraperService(downloader MangaDownloaderInterface, repository core.Mang
 	}
 }
 
-func (s *ScraperService) DownloadAndSaveManga(mangaID string) error {
+func (s *ScraperService) DownloadAndSaveManga(ctx context.Context, mangaID string) error {
 	// Start the download process
 	log.Printf("Starting download for manga ID: %s", mangaID)
-	if err := s.Downloader.Run(); err != nil {
+	if err := s.Downloader.Run(ctx); err != nil {
 		return fmt.Errorf("failed to download manga: %w", err)
 	}
 
 	// Get manga metadata
-	mangaTitle, err := s.Downloader.GetMangaTitle()
+	manga, err := s.Downloader.FetchMetadata(ctx)
 	if err != nil {
-		log.Printf("Warning: Could not get manga title: %v", err)
-		mangaTitle = "unknown"
-	}
-
-	mangaStatus, err := s.Downloader.GetMangaStatus()
-	if err != nil {
-		log.Printf("Warning: Could not get manga status: %v", err)
-		mangaStatus = "unknown"
-	}
-
-	// Create a Manga object
-	manga := core.Manga{
-		ID:          mangaID,
-		Title:       mangaTitle,
-		Description: fmt.Sprintf("Status: %s", mangaStatus),
-		Authors:     []string{},
-		Genres:      []string{},
-		CoverPath:   "",
-		Chapters:    []core.Chapter{},
+		log.Printf("Warning: Could not get manga metadata: %v", err)
+		manga.Title = "unknown"
 	}
+	manga.ID = mangaID
+	manga.Chapters = s.Downloader.Chapters()
+	manga.UpdatedAt = time.Now().UTC()
 
 	// Save manga metadata to the repository
 	if err := s.Repository.SaveManga(manga); err != nil {
 		return fmt.Errorf("failed to save manga metadata: %w", err)
 	}
 
-	log.Printf("Successfully downloaded and saved manga: %s", mangaTitle)
+	log.Printf("Successfully downloaded and saved manga: %s", manga.Title)
 	return nil
 }
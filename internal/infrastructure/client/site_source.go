@@ -0,0 +1,395 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sucumbap/mangaroo/internal/core"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/browser"
+)
+
+// SiteSource is a Source driven by a SiteDefinition.
+type SiteSource struct {
+	def *SiteDefinition
+}
+
+func NewSiteSource(def *SiteDefinition) *SiteSource {
+	return &SiteSource{def: def}
+}
+
+func (s *SiteSource) Name() string {
+	return s.def.Name
+}
+
+func (s *SiteSource) Hosts() []string {
+	return s.def.Hosts
+}
+
+func (s *SiteSource) Backend() string {
+	return s.def.Backend
+}
+
+func (s *SiteSource) Search(b browser.Browser, query string) ([]core.Manga, error) {
+	if s.def.Search.URL == "" {
+		return nil, fmt.Errorf("source %s does not support search", s.def.Name)
+	}
+
+	searchURL := strings.ReplaceAll(s.def.Search.URL, "{query}", url.QueryEscape(query))
+	if err := s.load(b, searchURL, s.def.Search.Wait, s.def.LoadWait); err != nil {
+		return nil, fmt.Errorf("failed to navigate to search page: %w", err)
+	}
+
+	rows, err := b.Extract(s.def.Search.Results, map[string]browser.Field{
+		"title": {Selector: s.def.Search.Title},
+		"url":   {Selector: s.def.Search.Link, Attrs: []string{s.def.Search.URLAttr}, Resolve: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract search results: %w", err)
+	}
+
+	// Some sites redirect a single exact match straight to the series page
+	if len(rows) == 0 {
+		title, err := extractText(b, s.def.Series.Title.Selectors)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract search results: %w", err)
+		}
+		location, err := b.Location()
+		if _, ok := s.def.SeriesID(location); err == nil && title != "" && ok {
+			rows = append(rows, map[string]string{"title": s.def.Series.Title.clean(title), "url": location})
+		}
+	}
+
+	mangas := make([]core.Manga, 0, len(rows))
+	for _, row := range rows {
+		id, ok := s.def.SeriesID(row["url"])
+		if !ok {
+			continue
+		}
+		mangas = append(mangas, core.Manga{
+			ID:     id,
+			Title:  row["title"],
+			URL:    row["url"],
+			Source: s.def.Name,
+		})
+	}
+	return mangas, nil
+}
+
+func (s *SiteSource) SeriesID(seriesURL string) (string, error) {
+	id, ok := s.def.SeriesID(seriesURL)
+	if !ok {
+		return "", fmt.Errorf("%w: %s doesn't match the %s series URLs", ErrInvalidSeriesURL, seriesURL, s.def.Name)
+	}
+	return id, nil
+}
+
+func (s *SiteSource) FetchSeries(b browser.Browser, seriesURL string) (core.Manga, error) {
+	id, err := s.SeriesID(seriesURL)
+	if err != nil {
+		return core.Manga{}, err
+	}
+	manga := core.Manga{
+		ID:     id,
+		Title:  "unknown",
+		URL:    seriesURL,
+		Source: s.def.Name,
+		Status: "Unknown",
+	}
+
+	log.Printf("Getting manga details for: %s", seriesURL)
+	if err := s.load(b, seriesURL, s.def.Wait, s.def.LoadWait); err != nil {
+		return manga, fmt.Errorf("failed to navigate to base URL: %w", err)
+	}
+
+	title, err := extractText(b, s.def.Series.Title.Selectors)
+	if err != nil {
+		return manga, fmt.Errorf("failed to extract manga title: %w", err)
+	}
+	if title = s.def.Series.Title.clean(title); title != "" {
+		manga.Title = title
+	}
+
+	if len(s.def.Series.Status.Selectors) > 0 {
+		status, err := extractText(b, s.def.Series.Status.Selectors)
+		if err != nil {
+			return manga, fmt.Errorf("failed to extract manga status: %w", err)
+		}
+		if status = s.def.Series.Status.clean(status); status != "" {
+			manga.Status = status
+		}
+	}
+
+	if len(s.def.Series.Description.Selectors) > 0 {
+		description, err := extractText(b, s.def.Series.Description.Selectors)
+		if err != nil {
+			return manga, fmt.Errorf("failed to extract manga description: %w", err)
+		}
+		manga.Description = s.def.Series.Description.clean(description)
+	}
+
+	lists := []struct {
+		name  string
+		field ListField
+		dest  *[]string
+	}{
+		{"alternative titles", s.def.Series.AltTitles, &manga.AltTitles},
+		{"authors", s.def.Series.Authors, &manga.Authors},
+		{"genres", s.def.Series.Genres, &manga.Genres},
+	}
+	for _, list := range lists {
+		*list.dest = []string{}
+		if list.field.Selector == "" {
+			continue
+		}
+		values, err := extractAll(b, list.field.Selector, browser.Field{})
+		if err != nil {
+			return manga, fmt.Errorf("failed to extract manga %s: %w", list.name, err)
+		}
+		*list.dest = list.field.clean(values)
+	}
+
+	if s.def.Series.Cover.Selector != "" {
+		covers, err := extractAll(b, s.def.Series.Cover.Selector, browser.Field{
+			Attrs:        s.def.Series.Cover.Attrs,
+			SkipPrefixes: []string{"data:"},
+			Resolve:      true,
+		})
+		if err != nil {
+			return manga, fmt.Errorf("failed to extract manga cover: %w", err)
+		}
+		if len(covers) > 0 {
+			manga.CoverURL = s.NormalizeImageURL(covers[0])
+		}
+	}
+
+	// The chapter list lives on the same page, so take it while we're here
+	chapters, err := s.extractChapters(b)
+	if err != nil {
+		return manga, err
+	}
+	manga.Chapters = chapters
+
+	return manga, nil
+}
+
+func (s *SiteSource) ListChapters(b browser.Browser, seriesURL string) ([]core.Chapter, error) {
+	if err := s.load(b, seriesURL, s.def.Wait, s.def.LoadWait); err != nil {
+		return nil, fmt.Errorf("failed to navigate to URL: %w", err)
+	}
+	return s.extractChapters(b)
+}
+
+// extractChapters reads the chapter list from the loaded series page.
+func (s *SiteSource) extractChapters(b browser.Browser) ([]core.Chapter, error) {
+	fields := map[string]browser.Field{
+		"url":   {Selector: s.def.Chapters.Link, Attrs: []string{s.def.Chapters.URLAttr}, Resolve: true},
+		"title": {Selector: s.def.Chapters.Title},
+	}
+	if s.def.Chapters.Uploaded != "" {
+		fields["uploaded"] = browser.Field{Selector: s.def.Chapters.Uploaded}
+	}
+
+	rows, err := b.Extract(s.def.Chapters.List, fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract chapter list: %w", err)
+	}
+
+	if s.def.Chapters.Order == OrderNewestFirst {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	seen := make(map[string]bool)
+	chapters := make([]core.Chapter, 0, len(rows))
+	for _, row := range rows {
+		if row["url"] == "" {
+			continue
+		}
+
+		number, ok := s.def.ChapterNumber(row["url"], row["title"])
+		if !ok {
+			log.Printf("Skipping chapter without a number: %s (%q)", row["url"], row["title"])
+			continue
+		}
+		if seen[number] {
+			log.Printf("Skipping duplicate chapter %s: %s", number, row["url"])
+			continue
+		}
+		seen[number] = true
+
+		chapters = append(chapters, core.Chapter{
+			ID:       number,
+			Number:   number,
+			Title:    row["title"],
+			URL:      row["url"],
+			Uploaded: row["uploaded"],
+		})
+	}
+	return chapters, nil
+}
+
+func (s *SiteSource) ListPages(b browser.Browser, chapterURL string) ([]string, error) {
+	loadWait := s.def.LoadWait
+	if s.def.Pages.LoadWait > 0 {
+		loadWait = s.def.Pages.LoadWait
+	}
+	if err := s.load(b, chapterURL, s.def.Pages.Wait, loadWait); err != nil {
+		return nil, fmt.Errorf("failed to navigate to chapter URL: %w", err)
+	}
+
+	field := browser.Field{
+		Attrs:        s.def.Pages.ImageAttrs,
+		SkipPrefixes: s.def.Pages.SkipPrefixes,
+	}
+	if s.def.Pages.Scroll {
+		timeout := time.Duration(s.def.Pages.ScrollTimeout) * time.Second
+		if _, err := b.AutoScroll(s.def.Pages.Images, field, timeout); err != nil {
+			return nil, fmt.Errorf("failed to scroll through chapter: %w", err)
+		}
+	}
+
+	rows, err := b.Extract(s.def.Pages.Images, map[string]browser.Field{"value": field})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract image URLs: %w", err)
+	}
+
+	// An image still showing a placeholder is a page we would lose
+	imageURLs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row["value"] != "" {
+			imageURLs = append(imageURLs, row["value"])
+		}
+	}
+	if missing := len(rows) - len(imageURLs); missing > 0 {
+		return nil, fmt.Errorf("%w: %d of %d images have no URL", ErrIncompleteChapter, missing, len(rows))
+	}
+
+	declared, err := s.declaredPageCount(b)
+	if err != nil {
+		return nil, err
+	}
+	if declared > len(imageURLs) {
+		return nil, fmt.Errorf("%w: found %d of %d pages", ErrIncompleteChapter, len(imageURLs), declared)
+	}
+	return imageURLs, nil
+}
+
+// ErrIncompleteChapter is returned when some pages of a chapter could not
+// be found, so it isn't saved truncated.
+var ErrIncompleteChapter = fmt.Errorf("chapter is incomplete")
+
+// declaredPageCount returns the page count stated by the site, or zero
+// if the site definition doesn't say where to find it.
+func (s *SiteSource) declaredPageCount(b browser.Browser) (int, error) {
+	def := s.def.Pages.PageCount
+	if def.Selector == "" {
+		return 0, nil
+	}
+
+	rows, err := b.Extract(def.Selector, map[string]browser.Field{"text": {}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to extract page count: %w", err)
+	}
+	if def.pattern == nil {
+		return len(rows), nil
+	}
+	if len(rows) == 0 {
+		log.Printf("Warning: no page count found with %q", def.Selector)
+		return 0, nil
+	}
+
+	m := def.pattern.FindStringSubmatch(rows[0]["text"])
+	if m == nil {
+		log.Printf("Warning: page count %q does not match %q", rows[0]["text"], def.Pattern)
+		return 0, nil
+	}
+	count, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid page count %q: %w", m[1], err)
+	}
+	return count, nil
+}
+
+func (s *SiteSource) NormalizeImageURL(imageURL string) string {
+	switch {
+	case strings.HasPrefix(imageURL, "http"):
+	case strings.HasPrefix(imageURL, "//"):
+		imageURL = "https:" + imageURL
+	case !strings.HasPrefix(imageURL, "/"):
+		imageURL = "https://" + imageURL
+	default:
+		imageURL = s.def.BaseURL + imageURL
+	}
+	return s.def.Rewrite(imageURL)
+}
+
+// load navigates to pageURL and waits until the page is ready. Without a
+// wait condition the page gets loadWait seconds to settle.
+func (s *SiteSource) load(b browser.Browser, pageURL string, wait WaitDefinition, loadWait int) error {
+	if err := b.Navigate(pageURL); err != nil {
+		return err
+	}
+	if wait.Selector == "" && !wait.NetworkIdle {
+		return b.Bsleep(loadWait)
+	}
+
+	// A missed condition is logged rather than fatal, the page may still
+	// hold what we need
+	timeout := time.Duration(wait.Timeout) * time.Second
+	switch {
+	case wait.MinCount > 0:
+		if err := b.WaitCount(wait.Selector, wait.MinCount, timeout); err != nil {
+			log.Printf("Warning: %s: %v", pageURL, err)
+		}
+	case wait.Selector != "":
+		if err := b.WaitVisible(wait.Selector, timeout); err != nil {
+			log.Printf("Warning: %s: %v", pageURL, err)
+		}
+	}
+	if wait.NetworkIdle {
+		if err := b.WaitNetworkIdle(networkIdleTime, timeout); err != nil {
+			log.Printf("Warning: %s: %v", pageURL, err)
+		}
+	}
+	return nil
+}
+
+// networkIdleTime is how long a page must make no requests to count as
+// loaded.
+const networkIdleTime = 500 * time.Millisecond
+
+// extractAll reads a single field from every element matching selector
+// and drops empty values.
+func extractAll(b browser.Browser, selector string, field browser.Field) ([]string, error) {
+	rows, err := b.Extract(selector, map[string]browser.Field{"value": field})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row["value"] != "" {
+			values = append(values, row["value"])
+		}
+	}
+	return values, nil
+}
+
+// extractText returns the text of the first selector with a non-empty match.
+func extractText(b browser.Browser, selectors []string) (string, error) {
+	for _, selector := range selectors {
+		values, err := extractAll(b, selector, browser.Field{})
+		if err != nil {
+			return "", err
+		}
+		if len(values) > 0 {
+			return values[0], nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,459 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/browser"
+	"gopkg.in/yaml.v3"
+)
+
+// SiteDefinition describes how to scrape a manga site. Definitions are
+// loaded from YAML files so selector changes don't require a rebuild.
+type SiteDefinition struct {
+	Name    string   `yaml:"name"`
+	Hosts   []string `yaml:"hosts"`
+	BaseURL string   `yaml:"base_url"`
+	// Backend is chrome for sites that render with JavaScript or http for
+	// server rendered ones
+	Backend string `yaml:"backend"`
+	// LoadWait is the number of seconds to let a page settle after
+	// navigating when no wait condition is given
+	LoadWait int `yaml:"load_wait"`
+	// Wait tells when series and chapter list pages are ready
+	Wait     WaitDefinition     `yaml:"wait"`
+	Search   SearchDefinition   `yaml:"search"`
+	Series   SeriesDefinition   `yaml:"series"`
+	Chapters ChaptersDefinition `yaml:"chapters"`
+	Pages    PagesDefinition    `yaml:"pages"`
+	Rewrites []RewriteRule      `yaml:"rewrites"`
+
+	idPattern *regexp.Regexp
+}
+
+// WaitDefinition tells when a page is ready to be scraped.
+type WaitDefinition struct {
+	// Selector must match a visible element or, when MinCount is set, at
+	// least MinCount elements whether visible or not
+	Selector string `yaml:"selector"`
+	MinCount int    `yaml:"min_count"`
+	// NetworkIdle waits until the page stopped making requests
+	NetworkIdle bool `yaml:"network_idle"`
+	// Timeout is the number of seconds to wait at most
+	Timeout int `yaml:"timeout"`
+}
+
+// TextField extracts a text value from the first selector that matches.
+type TextField struct {
+	Selectors []string `yaml:"selectors"`
+	// Split keeps only the part of the text before this separator
+	Split string `yaml:"split"`
+	// Strip lists substrings removed from the text
+	Strip []string `yaml:"strip"`
+}
+
+type SearchDefinition struct {
+	// URL is the search page with a {query} placeholder
+	URL     string         `yaml:"url"`
+	Results string         `yaml:"results"`
+	Title   string         `yaml:"title"`
+	Link    string         `yaml:"link"`
+	URLAttr string         `yaml:"url_attr"`
+	Wait    WaitDefinition `yaml:"wait"`
+}
+
+// ListField extracts the text of every element matching Selector.
+type ListField struct {
+	Selector string `yaml:"selector"`
+	// Split breaks each text into several values on this separator
+	Split string `yaml:"split"`
+}
+
+// ImageField extracts an image URL, trying Attrs in order.
+type ImageField struct {
+	Selector string   `yaml:"selector"`
+	Attrs    []string `yaml:"attrs"`
+}
+
+type SeriesDefinition struct {
+	// IDPattern captures the series ID from its URL
+	IDPattern   string     `yaml:"id_pattern"`
+	Title       TextField  `yaml:"title"`
+	AltTitles   ListField  `yaml:"alt_titles"`
+	Status      TextField  `yaml:"status"`
+	Description TextField  `yaml:"description"`
+	Authors     ListField  `yaml:"authors"`
+	Genres      ListField  `yaml:"genres"`
+	Cover       ImageField `yaml:"cover"`
+}
+
+type ChaptersDefinition struct {
+	// List matches one element per chapter, the other selectors are
+	// relative to it
+	List     string `yaml:"list"`
+	Link     string `yaml:"link"`
+	URLAttr  string `yaml:"url_attr"`
+	Title    string `yaml:"title"`
+	Uploaded string `yaml:"uploaded"`
+	// NumberPattern captures the chapter number from the chapter URL,
+	// falling back to the first number in the title text. By default the
+	// first number of the URL's last path segment is taken
+	NumberPattern string `yaml:"number_pattern"`
+	// Order is how the site lists chapters: oldest_first or newest_first
+	Order string `yaml:"order"`
+
+	numberPattern *regexp.Regexp
+}
+
+type PagesDefinition struct {
+	// LoadWait overrides the site wide load_wait for chapter pages
+	LoadWait int            `yaml:"load_wait"`
+	Wait     WaitDefinition `yaml:"wait"`
+	Images   string         `yaml:"images"`
+	// ImageAttrs are tried in order, the first non-empty one wins
+	ImageAttrs []string `yaml:"image_attrs"`
+	// SkipPrefixes drops placeholder URLs such as inline data: images
+	SkipPrefixes []string `yaml:"skip_prefixes"`
+	// Scroll scrolls through the reader so lazy loaded images get their URLs
+	Scroll bool `yaml:"scroll"`
+	// ScrollTimeout is the number of seconds to scroll at most
+	ScrollTimeout int `yaml:"scroll_timeout"`
+	// PageCount reads the number of pages the site says the chapter has
+	PageCount PageCountDefinition `yaml:"page_count"`
+}
+
+// PageCountDefinition reads a declared page count. Without a Pattern the
+// matches of Selector are counted, otherwise Pattern captures the count
+// from the text of the first match, as in "Page 1 of (\d+)".
+type PageCountDefinition struct {
+	Selector string `yaml:"selector"`
+	Pattern  string `yaml:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+// RewriteRule replaces every match of Pattern in an image URL.
+type RewriteRule struct {
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+
+	re *regexp.Regexp
+}
+
+const (
+	defaultIDPattern     = `/manga/([^/?#]+)`
+	defaultNumberPattern = `(\d+(?:\.\d+)?)`
+	defaultWaitTimeout   = 15
+	defaultScrollTimeout = 60
+
+	OrderOldestFirst = "oldest_first"
+	OrderNewestFirst = "newest_first"
+)
+
+// LoadSiteDefinitions parses and validates every *.yml and *.yaml file
+// in dir.
+func LoadSiteDefinitions(dir string) ([]*SiteDefinition, error) {
+	var files []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list site definitions: %w", err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no site definitions found in %s", dir)
+	}
+
+	names := make(map[string]string)
+	var defs []*SiteDefinition
+	for _, file := range files {
+		def, err := LoadSiteDefinition(file)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[def.Name]; ok {
+			return nil, fmt.Errorf("site %q is defined in both %s and %s", def.Name, other, file)
+		}
+		names[def.Name] = file
+		defs = append(defs, def)
+		log.Printf("Loaded site definition %q from %s", def.Name, file)
+	}
+	return defs, nil
+}
+
+// LoadSiteDefinition parses and validates a single definition file.
+func LoadSiteDefinition(path string) (*SiteDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read site definition: %w", err)
+	}
+
+	var def SiteDefinition
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&def); err != nil {
+		return nil, fmt.Errorf("failed to parse site definition %s: %w", path, err)
+	}
+
+	if err := def.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid site definition %s: %w", path, err)
+	}
+	return &def, nil
+}
+
+// Validate checks the definition, compiles its patterns and fills in
+// defaults.
+func (d *SiteDefinition) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(d.Hosts) == 0 {
+		return fmt.Errorf("at least one host is required")
+	}
+
+	base, err := url.Parse(d.BaseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return fmt.Errorf("base_url must be an absolute URL, got %q", d.BaseURL)
+	}
+	d.BaseURL = strings.TrimSuffix(d.BaseURL, "/")
+
+	switch d.Backend {
+	case "":
+		d.Backend = browser.BackendChrome
+	case browser.BackendChrome, browser.BackendHTTP:
+	default:
+		return fmt.Errorf("backend must be %s or %s, got %q", browser.BackendChrome, browser.BackendHTTP, d.Backend)
+	}
+
+	if d.Series.IDPattern == "" {
+		d.Series.IDPattern = defaultIDPattern
+	}
+	if d.idPattern, err = regexp.Compile(d.Series.IDPattern); err != nil {
+		return fmt.Errorf("series.id_pattern: %w", err)
+	}
+	if d.idPattern.NumSubexp() != 1 {
+		return fmt.Errorf("series.id_pattern must have exactly one capture group")
+	}
+
+	if len(d.Series.Title.Selectors) == 0 {
+		return fmt.Errorf("series.title.selectors is required")
+	}
+	if d.Chapters.List == "" {
+		return fmt.Errorf("chapters.list is required")
+	}
+	if d.Pages.Images == "" {
+		return fmt.Errorf("pages.images is required")
+	}
+
+	if d.Chapters.Link == "" {
+		d.Chapters.Link = "a"
+	}
+	if d.Chapters.URLAttr == "" {
+		d.Chapters.URLAttr = "href"
+	}
+	if d.Chapters.Title == "" {
+		d.Chapters.Title = d.Chapters.Link
+	}
+	if d.Chapters.NumberPattern == "" {
+		d.Chapters.NumberPattern = defaultNumberPattern
+	}
+	if d.Chapters.numberPattern, err = regexp.Compile(d.Chapters.NumberPattern); err != nil {
+		return fmt.Errorf("chapters.number_pattern: %w", err)
+	}
+	if d.Chapters.numberPattern.NumSubexp() != 1 {
+		return fmt.Errorf("chapters.number_pattern must have exactly one capture group")
+	}
+	switch d.Chapters.Order {
+	case "":
+		d.Chapters.Order = OrderOldestFirst
+	case OrderOldestFirst, OrderNewestFirst:
+	default:
+		return fmt.Errorf("chapters.order must be %s or %s, got %q", OrderOldestFirst, OrderNewestFirst, d.Chapters.Order)
+	}
+	if d.Pages.ScrollTimeout == 0 {
+		d.Pages.ScrollTimeout = defaultScrollTimeout
+	}
+	if d.Pages.PageCount.Pattern != "" {
+		if d.Pages.PageCount.Selector == "" {
+			return fmt.Errorf("pages.page_count.pattern needs a selector")
+		}
+		if d.Pages.PageCount.pattern, err = regexp.Compile(d.Pages.PageCount.Pattern); err != nil {
+			return fmt.Errorf("pages.page_count.pattern: %w", err)
+		}
+		if d.Pages.PageCount.pattern.NumSubexp() != 1 {
+			return fmt.Errorf("pages.page_count.pattern must have exactly one capture group")
+		}
+	}
+	if d.Pages.ImageAttrs == nil {
+		d.Pages.ImageAttrs = []string{"src"}
+	}
+	if d.Series.Cover.Attrs == nil {
+		d.Series.Cover.Attrs = []string{"src"}
+	}
+
+	if d.Search.URL != "" {
+		if !strings.Contains(d.Search.URL, "{query}") {
+			return fmt.Errorf("search.url must contain a {query} placeholder")
+		}
+		if d.Search.Results == "" {
+			return fmt.Errorf("search.results is required when search.url is set")
+		}
+		if d.Search.URLAttr == "" {
+			d.Search.URLAttr = "href"
+		}
+	}
+
+	selectors := map[string]string{
+		"chapters.list":     d.Chapters.List,
+		"chapters.link":     d.Chapters.Link,
+		"chapters.title":    d.Chapters.Title,
+		"chapters.uploaded": d.Chapters.Uploaded,
+		"pages.images":      d.Pages.Images,
+		"pages.page_count":  d.Pages.PageCount.Selector,
+		"series.alt_titles": d.Series.AltTitles.Selector,
+		"series.authors":    d.Series.Authors.Selector,
+		"series.genres":     d.Series.Genres.Selector,
+		"series.cover":      d.Series.Cover.Selector,
+		"search.results":    d.Search.Results,
+		"search.title":      d.Search.Title,
+		"search.link":       d.Search.Link,
+	}
+	for i, sel := range d.Series.Title.Selectors {
+		selectors[fmt.Sprintf("series.title.selectors[%d]", i)] = sel
+	}
+	for i, sel := range d.Series.Status.Selectors {
+		selectors[fmt.Sprintf("series.status.selectors[%d]", i)] = sel
+	}
+	for i, sel := range d.Series.Description.Selectors {
+		selectors[fmt.Sprintf("series.description.selectors[%d]", i)] = sel
+	}
+	for field, sel := range selectors {
+		if sel == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(sel); err != nil {
+			return fmt.Errorf("%s: invalid selector %q: %w", field, sel, err)
+		}
+	}
+
+	waits := map[string]*WaitDefinition{
+		"wait":        &d.Wait,
+		"search.wait": &d.Search.Wait,
+		"pages.wait":  &d.Pages.Wait,
+	}
+	for field, wait := range waits {
+		if wait.Timeout == 0 {
+			wait.Timeout = defaultWaitTimeout
+		}
+		if wait.Timeout < 0 || wait.MinCount < 0 {
+			return fmt.Errorf("%s: timeout and min_count must not be negative", field)
+		}
+		if wait.MinCount > 0 && wait.Selector == "" {
+			return fmt.Errorf("%s: min_count needs a selector", field)
+		}
+		if wait.Selector == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(wait.Selector); err != nil {
+			return fmt.Errorf("%s.selector: invalid selector %q: %w", field, wait.Selector, err)
+		}
+	}
+
+	for i := range d.Rewrites {
+		rule := &d.Rewrites[i]
+		if rule.re, err = regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("rewrites[%d].pattern: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// SeriesID extracts the series ID from a series URL, false if the URL
+// doesn't match series.id_pattern.
+func (d *SiteDefinition) SeriesID(seriesURL string) (string, bool) {
+	if m := d.idPattern.FindStringSubmatch(seriesURL); m != nil && m[1] != "" {
+		return m[1], true
+	}
+	return "", false
+}
+
+// ChapterNumber extracts the chapter label from its URL or, failing
+// that, from its title.
+func (d *SiteDefinition) ChapterNumber(chapterURL, title string) (string, bool) {
+	if d.Chapters.NumberPattern == defaultNumberPattern {
+		// Hosts and series slugs contain numbers too, as in
+		// /manga/20th-century-boys/chapter-3
+		chapterURL = lastPathSegment(chapterURL)
+	}
+	for _, text := range []string{chapterURL, title} {
+		if m := d.Chapters.numberPattern.FindStringSubmatch(text); m != nil {
+			return m[1], true
+		}
+	}
+	if m := titleNumberPattern.FindStringSubmatch(title); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+var titleNumberPattern = regexp.MustCompile(defaultNumberPattern)
+
+func lastPathSegment(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return segments[len(segments)-1]
+}
+
+// Rewrite applies the rewrite rules to an image URL.
+func (d *SiteDefinition) Rewrite(imageURL string) string {
+	for _, rule := range d.Rewrites {
+		imageURL = rule.re.ReplaceAllString(imageURL, rule.Replace)
+	}
+	return imageURL
+}
+
+// clean splits and trims the extracted texts and drops duplicates.
+func (f ListField) clean(texts []string) []string {
+	seen := make(map[string]bool)
+	values := []string{}
+	for _, text := range texts {
+		parts := []string{text}
+		if f.Split != "" {
+			parts = strings.Split(text, f.Split)
+		}
+		for _, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" || seen[part] {
+				continue
+			}
+			seen[part] = true
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// clean applies the Split and Strip options of the field.
+func (f TextField) clean(text string) string {
+	if f.Split != "" {
+		text = strings.SplitN(text, f.Split, 2)[0]
+	}
+	for _, s := range f.Strip {
+		text = strings.ReplaceAll(text, s, "")
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const minimalDefinition = `
+name: example
+hosts:
+  - example.com
+base_url: https://example.com/
+series:
+  title:
+    selectors:
+      - h1
+chapters:
+  list: ul.chapters li
+pages:
+  images: div#pages img
+`
+
+func writeDefinition(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "site.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSiteDefinitionDefaults(t *testing.T) {
+	def, err := LoadSiteDefinition(writeDefinition(t, minimalDefinition))
+	if err != nil {
+		t.Fatalf("LoadSiteDefinition: %v", err)
+	}
+
+	if def.BaseURL != "https://example.com" {
+		t.Errorf("BaseURL = %q, want the trailing slash trimmed", def.BaseURL)
+	}
+	if def.Series.IDPattern != defaultIDPattern {
+		t.Errorf("IDPattern = %q, want the default", def.Series.IDPattern)
+	}
+	if def.Chapters.Link != "a" || def.Chapters.URLAttr != "href" {
+		t.Errorf("chapter link = %q %q, want a href", def.Chapters.Link, def.Chapters.URLAttr)
+	}
+	if len(def.Pages.ImageAttrs) != 1 || def.Pages.ImageAttrs[0] != "src" {
+		t.Errorf("ImageAttrs = %v, want [src]", def.Pages.ImageAttrs)
+	}
+}
+
+func TestLoadSiteDefinitionUnknownField(t *testing.T) {
+	_, err := LoadSiteDefinition(writeDefinition(t, minimalDefinition+"selector: h1\n"))
+	if err == nil || !strings.Contains(err.Error(), "selector") {
+		t.Fatalf("LoadSiteDefinition error = %v, want the unknown field reported", err)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	valid := func() *SiteDefinition {
+		def := &SiteDefinition{Name: "example", Hosts: []string{"example.com"}, BaseURL: "https://example.com"}
+		def.Series.Title.Selectors = []string{"h1"}
+		def.Chapters.List = "ul.chapters li"
+		def.Pages.Images = "div#pages img"
+		return def
+	}
+
+	cases := map[string]struct {
+		change func(d *SiteDefinition)
+		want   string
+	}{
+		"no name":            {func(d *SiteDefinition) { d.Name = "" }, "name is required"},
+		"no hosts":           {func(d *SiteDefinition) { d.Hosts = nil }, "host"},
+		"relative base_url":  {func(d *SiteDefinition) { d.BaseURL = "example.com" }, "base_url"},
+		"id without group":   {func(d *SiteDefinition) { d.Series.IDPattern = "/manga/.+" }, "capture group"},
+		"no title selectors": {func(d *SiteDefinition) { d.Series.Title.Selectors = nil }, "series.title.selectors"},
+		"no chapter list":    {func(d *SiteDefinition) { d.Chapters.List = "" }, "chapters.list"},
+		"no images":          {func(d *SiteDefinition) { d.Pages.Images = "" }, "pages.images"},
+		"bad selector":       {func(d *SiteDefinition) { d.Pages.Images = "div[" }, "pages.images: invalid selector"},
+		"search without query": {func(d *SiteDefinition) {
+			d.Search.URL = "https://example.com/search"
+			d.Search.Results = "div.item"
+		}, "{query}"},
+		"search without results": {func(d *SiteDefinition) { d.Search.URL = "https://example.com/?q={query}" }, "search.results"},
+	}
+	for name, c := range cases {
+		def := valid()
+		c.change(def)
+		if err := def.Validate(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: Validate() = %v, want an error mentioning %q", name, err, c.want)
+		}
+	}
+
+	if err := valid().Validate(); err != nil {
+		t.Errorf("Validate() of the valid definition = %v", err)
+	}
+}
+
+func TestShippedSiteDefinitions(t *testing.T) {
+	defs, err := LoadSiteDefinitions(filepath.Join("..", "..", "..", "configs", "sites"))
+	if err != nil {
+		t.Fatalf("LoadSiteDefinitions: %v", err)
+	}
+	if len(defs) == 0 {
+		t.Fatal("no site definitions shipped")
+	}
+}
+
+func TestSeriesID(t *testing.T) {
+	def, err := LoadSiteDefinition(writeDefinition(t, minimalDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for url, want := range map[string]string{
+		"https://example.com/manga/one-piece":  "one-piece",
+		"https://example.com/manga/foo.123/c5": "foo.123",
+		"https://example.com/manga/foo?page=2": "foo",
+	} {
+		if got, ok := def.SeriesID(url); !ok || got != want {
+			t.Errorf("SeriesID(%q) = %q, %v, want %q", url, got, ok, want)
+		}
+	}
+
+	if got, ok := def.SeriesID("https://example.com/read/one-piece/chapter"); ok {
+		t.Errorf("SeriesID of a reader URL = %q, want no match", got)
+	}
+}
+
+func TestChapterNumber(t *testing.T) {
+	def, err := LoadSiteDefinition(writeDefinition(t, strings.Replace(minimalDefinition,
+		"list: ul.chapters li", `list: ul.chapters li
+  number_pattern: '/c(\d+(?:\.\d+)?)/?$'`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url, title string
+		want       string
+		ok         bool
+	}{
+		{"https://example.com/manga/foo.123/c12", "Chapter 12", "12", true},
+		{"https://example.com/manga/foo.123/c12.5/", "", "12.5", true},
+		// The title is only read when the URL doesn't match
+		{"https://example.com/manga/foo.123/extra", "Chapter 7: The End", "7", true},
+		{"https://example.com/manga/foo.123/extra", "Oneshot", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := def.ChapterNumber(tt.url, tt.title)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ChapterNumber(%q, %q) = %q, %v, want %q, %v", tt.url, tt.title, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestChapterNumberDefaultPattern(t *testing.T) {
+	def, err := LoadSiteDefinition(writeDefinition(t, minimalDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url, title string
+		want       string
+	}{
+		{"https://example.com/manga/20th-century-boys/chapter-3", "Chapter 3", "3"},
+		{"https://example.com/manga/foo.123/c12.5/", "", "12.5"},
+		{"https://m2.example.com/manga/foo/chapter-7", "", "7"},
+		{"https://example.com/read?id=abc", "Chapter 7: The End", "7"},
+	}
+	for _, tt := range tests {
+		if got, ok := def.ChapterNumber(tt.url, tt.title); !ok || got != tt.want {
+			t.Errorf("ChapterNumber(%q, %q) = %q, %v, want %q", tt.url, tt.title, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/sucumbap/mangaroo/internal/core"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/browser"
+)
+
+// Source knows how to scrape a single manga site. Implementations are
+// stateless and receive the browser to drive on every call, so one
+// instance can serve any number of concurrent downloads.
+type Source interface {
+	// Name returns the unique identifier of the source
+	Name() string
+	// Hosts returns the URL hosts served by the source
+	Hosts() []string
+	// Backend returns the browser backend the site needs, see browser.New
+	Backend() string
+	// SeriesID returns the canonical ID of the series at seriesURL. It
+	// fails with ErrInvalidSeriesURL when the URL isn't a series page.
+	SeriesID(seriesURL string) (string, error)
+	// Search looks up series matching the query
+	Search(b browser.Browser, query string) ([]core.Manga, error)
+	// FetchSeries scrapes the series metadata and chapter list from its
+	// page in a single navigation
+	FetchSeries(b browser.Browser, seriesURL string) (core.Manga, error)
+	// ListChapters returns the chapters of the series in reading order
+	ListChapters(b browser.Browser, seriesURL string) ([]core.Chapter, error)
+	// ListPages returns the image URLs of a chapter in reading order
+	ListPages(b browser.Browser, chapterURL string) ([]string, error)
+	// NormalizeImageURL turns a scraped image reference into an absolute URL
+	NormalizeImageURL(imageURL string) string
+}
+
+// Registry maps URL hosts to the source that handles them.
+type Registry struct {
+	mu      sync.RWMutex
+	sources map[string]Source
+	hosts   map[string]Source
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		sources: make(map[string]Source),
+		hosts:   make(map[string]Source),
+	}
+}
+
+// LoadRegistry builds a registry from the site definitions in dir.
+func LoadRegistry(dir string) (*Registry, error) {
+	defs, err := LoadSiteDefinitions(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := NewRegistry()
+	for _, def := range defs {
+		registry.Register(NewSiteSource(def))
+	}
+	return registry, nil
+}
+
+// Register adds src to the registry, replacing any source previously
+// registered under the same name or hosts.
+func (r *Registry) Register(src Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sources[src.Name()] = src
+	for _, host := range src.Hosts() {
+		r.hosts[normalizeHost(host)] = src
+	}
+}
+
+// Get returns the source registered under name.
+func (r *Registry) Get(name string) (Source, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	src, ok := r.sources[name]
+	return src, ok
+}
+
+// ForURL picks the source responsible for the host of rawURL.
+func (r *Registry) ForURL(rawURL string) (Source, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: missing host", rawURL)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	src, ok := r.hosts[normalizeHost(parsed.Hostname())]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSource, parsed.Hostname())
+	}
+	return src, nil
+}
+
+// ErrUnsupportedSource is returned when no source handles a URL.
+var ErrUnsupportedSource = fmt.Errorf("no source registered for host")
+
+// ErrInvalidSeriesURL is returned when a URL isn't a series page of the
+// source handling its host.
+var ErrInvalidSeriesURL = fmt.Errorf("not a series URL")
+
+func normalizeHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter hands out a token bucket per host. One Limiter is shared by the
+// whole process so every job, and both image requests and browser
+// navigations, draw from the same budget for a given site.
+type Limiter struct {
+	limit rate.Limit
+	burst int
+
+	mu    sync.Mutex
+	hosts map[string]*rate.Limiter
+}
+
+type LimiterInterface interface {
+	Wait(ctx context.Context, rawURL string) error
+}
+
+// New allows requestsPerSecond requests per host with bursts of up to
+// burst requests. A rate of zero or less disables limiting.
+func New(requestsPerSecond float64, burst int) *Limiter {
+	limit := rate.Limit(requestsPerSecond)
+	if requestsPerSecond <= 0 {
+		limit = rate.Inf
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &Limiter{
+		limit: limit,
+		burst: burst,
+		hosts: make(map[string]*rate.Limiter),
+	}
+}
+
+// Wait blocks until a request to the host of rawURL is allowed or ctx is
+// done. A nil Limiter never blocks.
+func (l *Limiter) Wait(ctx context.Context, rawURL string) error {
+	if l == nil {
+		return nil
+	}
+	return l.forHost(hostOf(rawURL)).Wait(ctx)
+}
+
+func (l *Limiter) forHost(host string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.hosts[host]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.hosts[host] = limiter
+	}
+	return limiter
+}
+
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
@@ -22,6 +22,17 @@ func NewElasticMangaRepository(client *ElasticClient, indexPrefix string) *Elast
 	}
 }
 
+// catalogID is the document ID of a series in the catalog. Series IDs are
+// only unique within their source.
+func catalogID(source, mangaID string) string {
+	return source + ":" + mangaID
+}
+
+// IndexName returns the catalog index the repository stores series in.
+func (r *ElasticMangaRepository) IndexName() string {
+	return fmt.Sprintf("%s_manga", r.indexPrefix)
+}
+
 func (r *ElasticMangaRepository) SaveManga(manga core.Manga) error {
 	indexName := fmt.Sprintf("%s_manga", r.indexPrefix)
 
@@ -30,8 +41,8 @@ func (r *ElasticMangaRepository) SaveManga(manga core.Manga) error {
 		return fmt.Errorf("failed to ensure index exists: %w", err)
 	}
 
-	// Marshal manga to JSON
-	docJSON, err := json.Marshal(manga)
+	// Marshal manga to JSON, with the inputs of its title suggestions
+	docJSON, err := json.Marshal(newCatalogDoc(manga))
 	if err != nil {
 		return fmt.Errorf("failed to marshal manga: %w", err)
 	}
@@ -39,7 +50,7 @@ func (r *ElasticMangaRepository) SaveManga(manga core.Manga) error {
 	// Index document
 	req := esapi.IndexRequest{
 		Index:      indexName,
-		DocumentID: manga.ID,
+		DocumentID: catalogID(manga.Source, manga.ID),
 		Body:       strings.NewReader(string(docJSON)),
 		Refresh:    "true",
 	}
@@ -57,12 +68,12 @@ func (r *ElasticMangaRepository) SaveManga(manga core.Manga) error {
 	return nil
 }
 
-func (r *ElasticMangaRepository) GetMangaByID(id string) (core.Manga, error) {
+func (r *ElasticMangaRepository) GetMangaByID(sourceName, id string) (core.Manga, error) {
 	indexName := fmt.Sprintf("%s_manga", r.indexPrefix)
 
 	req := esapi.GetRequest{
 		Index:      indexName,
-		DocumentID: id,
+		DocumentID: catalogID(sourceName, id),
 	}
 
 	res, err := req.Do(context.Background(), r.elasticClient.client)
@@ -147,12 +158,12 @@ func (r *ElasticMangaRepository) GetAllManga() ([]core.Manga, error) {
 	return mangas, nil
 }
 
-func (r *ElasticMangaRepository) DeleteManga(id string) error {
+func (r *ElasticMangaRepository) DeleteManga(sourceName, id string) error {
 	indexName := fmt.Sprintf("%s_manga", r.indexPrefix)
 
 	req := esapi.DeleteRequest{
 		Index:      indexName,
-		DocumentID: id,
+		DocumentID: catalogID(sourceName, id),
 	}
 
 	res, err := req.Do(context.Background(), r.elasticClient.client)
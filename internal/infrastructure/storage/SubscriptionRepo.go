@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sucumbap/mangaroo/internal/core"
+	apperrors "github.com/sucumbap/mangaroo/pkg/errors"
+)
+
+type ElasticSubscriptionRepository struct {
+	elasticClient *ElasticClient
+	indexPrefix   string
+}
+
+func NewElasticSubscriptionRepository(client *ElasticClient, indexPrefix string) *ElasticSubscriptionRepository {
+	return &ElasticSubscriptionRepository{
+		elasticClient: client,
+		indexPrefix:   indexPrefix,
+	}
+}
+
+func (r *ElasticSubscriptionRepository) indexName() string {
+	return fmt.Sprintf("%s_subscriptions", r.indexPrefix)
+}
+
+func (r *ElasticSubscriptionRepository) SaveSubscription(subscription core.Subscription) error {
+	indexName := r.indexName()
+
+	if err := r.elasticClient.EnsureIndex(indexName); err != nil {
+		return fmt.Errorf("failed to ensure index exists: %w", err)
+	}
+
+	docJSON, err := json.Marshal(subscription)
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscription: %w", err)
+	}
+
+	req := esapi.IndexRequest{
+		Index:      indexName,
+		DocumentID: subscription.ID,
+		Body:       strings.NewReader(string(docJSON)),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(context.Background(), r.elasticClient.client)
+	if err != nil {
+		return fmt.Errorf("failed to index subscription: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	return nil
+}
+
+func (r *ElasticSubscriptionRepository) GetSubscriptionByID(id string) (core.Subscription, error) {
+	req := esapi.GetRequest{
+		Index:      r.indexName(),
+		DocumentID: id,
+	}
+
+	res, err := req.Do(context.Background(), r.elasticClient.client)
+	if err != nil {
+		return core.Subscription{}, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return core.Subscription{}, apperrors.NewAppError(http.StatusNotFound, "subscription not found", nil)
+	}
+
+	if res.IsError() {
+		return core.Subscription{}, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	var result struct {
+		Source core.Subscription `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return core.Subscription{}, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	return result.Source, nil
+}
+
+func (r *ElasticSubscriptionRepository) GetAllSubscriptions() ([]core.Subscription, error) {
+	req := esapi.SearchRequest{
+		Index: []string{r.indexName()},
+		Body:  strings.NewReader(`{"size": 10000, "query": {"match_all": {}}}`),
+	}
+
+	res, err := req.Do(context.Background(), r.elasticClient.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search subscriptions: %w", err)
+	}
+	defer res.Body.Close()
+
+	// No subscription was ever saved
+	if res.StatusCode == 404 {
+		return []core.Subscription{}, nil
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source core.Subscription `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	subscriptions := make([]core.Subscription, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		subscriptions = append(subscriptions, hit.Source)
+	}
+	return subscriptions, nil
+}
+
+func (r *ElasticSubscriptionRepository) DeleteSubscription(id string) error {
+	req := esapi.DeleteRequest{
+		Index:      r.indexName(),
+		DocumentID: id,
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(context.Background(), r.elasticClient.client)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return apperrors.NewAppError(http.StatusNotFound, "subscription not found", nil)
+	}
+
+	if res.IsError() {
+		return fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	return nil
+}
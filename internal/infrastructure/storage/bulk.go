@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/blob"
+)
+
+// BulkOptions controls when a BulkIndexer sends its buffered documents.
+type BulkOptions struct {
+	// MaxDocs flushes once this many documents are buffered
+	MaxDocs int
+	// MaxBytes flushes once the request body reaches this size
+	MaxBytes int
+	// Retries is how often rejected or unsent documents are sent again
+	Retries int
+	// RetryDelay is the base delay of the exponential backoff
+	RetryDelay time.Duration
+}
+
+// BulkItemError is the failure Elasticsearch reported for one document of
+// a bulk request.
+type BulkItemError struct {
+	Index      string
+	DocumentID string
+	Status     int
+	Type       string
+	Reason     string
+}
+
+func (e *BulkItemError) Error() string {
+	return fmt.Sprintf("indexing %s/%s failed with status %d: %s: %s", e.Index, e.DocumentID, e.Status, e.Type, e.Reason)
+}
+
+type bulkItem struct {
+	index  string
+	id     string
+	body   []byte
+	onDone func(err error)
+}
+
+func (item bulkItem) done(err error) {
+	if item.onDone != nil {
+		item.onDone(err)
+	}
+}
+
+// BulkIndexer batches documents into _bulk requests. Every document's
+// onDone callback is called exactly once, from Add or Flush, with nil on
+// success. It is not safe for concurrent use.
+type BulkIndexer struct {
+	client  *elasticsearch.Client
+	opts    BulkOptions
+	pending []bulkItem
+	size    int
+}
+
+func (ec *ElasticClient) NewBulkIndexer(opts BulkOptions) *BulkIndexer {
+	if opts.MaxDocs <= 0 {
+		opts.MaxDocs = 500
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 5 << 20
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 500 * time.Millisecond
+	}
+	return &BulkIndexer{client: ec.client, opts: opts}
+}
+
+// Add buffers a document and flushes when the batch is full. It only
+// fails when the document can't be encoded, onDone isn't called then.
+// Failures of the flush go to the callbacks of the flushed documents.
+func (bi *BulkIndexer) Add(ctx context.Context, index, documentID string, doc interface{}, onDone func(err error)) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+
+	bi.pending = append(bi.pending, bulkItem{index: index, id: documentID, body: body, onDone: onDone})
+	bi.size += len(body)
+	if len(bi.pending) >= bi.opts.MaxDocs || bi.size >= bi.opts.MaxBytes {
+		if err := bi.Flush(ctx); err != nil {
+			log.Printf("Bulk request failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// AddMangaImage buffers a page document, see IndexMangaImage.
+func (bi *BulkIndexer) AddMangaImage(ctx context.Context, indexName string, chapterID string, imageNum int, image blob.Info, metadata map[string]interface{}, onDone func(err error)) error {
+	return bi.Add(ctx, indexName, pageDocumentID(chapterID, imageNum), mangaImageDoc(chapterID, imageNum, image, metadata), onDone)
+}
+
+// Flush sends the buffered documents. Documents rejected with 429 or 5xx,
+// or lost to a failed request, are retried with backoff. Documents
+// rejected by a migration's write block are retried until it is lifted,
+// for up to MaxWriteBlock. The returned error only covers the request
+// itself, per document failures go to the callbacks.
+func (bi *BulkIndexer) Flush(ctx context.Context) error {
+	items := bi.pending
+	bi.pending = nil
+	bi.size = 0
+
+	start := time.Now()
+	for attempt := 0; len(items) > 0; attempt++ {
+		errs, reqErr := bi.send(ctx, items)
+
+		var retry []bulkItem
+		for i, item := range items {
+			err := reqErr
+			if err == nil {
+				err = errs[i]
+			}
+			if err != nil && ctx.Err() == nil && bi.retry(err, reqErr, attempt, start) {
+				retry = append(retry, item)
+				continue
+			}
+			item.done(err)
+		}
+		if len(retry) == 0 {
+			return reqErr
+		}
+
+		// Waiting out a write block takes many attempts, cap the delay
+		delay := bi.opts.RetryDelay << uint(min(attempt, 4))
+		log.Printf("Retrying %d of %d bulk documents in %v", len(retry), len(items), delay)
+		select {
+		case <-ctx.Done():
+			for _, item := range retry {
+				item.done(ctx.Err())
+			}
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		items = retry
+	}
+	return nil
+}
+
+// retry reports whether a failed document is sent again.
+func (bi *BulkIndexer) retry(err, reqErr error, attempt int, start time.Time) bool {
+	if writeBlocked(err) {
+		return time.Since(start) < MaxWriteBlock
+	}
+	return attempt < bi.opts.Retries && retryableBulk(err, reqErr)
+}
+
+func writeBlocked(err error) bool {
+	itemErr, ok := err.(*BulkItemError)
+	return ok && itemErr.Type == "cluster_block_exception"
+}
+
+// retryableBulk reports whether a document may be accepted later. Failed
+// requests are always retried, rejected documents only on 429, 5xx and
+// write blocks. Migrations block writes to an index until its alias moves
+// to the new one.
+func retryableBulk(err, reqErr error) bool {
+	if reqErr != nil {
+		return true
+	}
+	itemErr, ok := err.(*BulkItemError)
+	if !ok {
+		return false
+	}
+	return itemErr.Status == 429 || itemErr.Status >= 500 || itemErr.Type == "cluster_block_exception"
+}
+
+// send issues a single _bulk request and returns the outcome of every
+// item in order.
+func (bi *BulkIndexer) send(ctx context.Context, items []bulkItem) ([]error, error) {
+	var buf bytes.Buffer
+	for _, item := range items {
+		meta, err := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": item.index, "_id": item.id},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal bulk action: %w", err)
+		}
+		buf.Write(meta)
+		buf.WriteByte('\n')
+		buf.Write(item.body)
+		buf.WriteByte('\n')
+	}
+
+	req := esapi.BulkRequest{Body: &buf}
+	res, err := req.Do(ctx, bi.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send bulk request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	var result struct {
+		Items []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing bulk response: %w", err)
+	}
+	if len(result.Items) != len(items) {
+		return nil, fmt.Errorf("bulk response has %d items, expected %d", len(result.Items), len(items))
+	}
+
+	errs := make([]error, len(items))
+	for i, entry := range result.Items {
+		for _, op := range entry {
+			if op.Status >= 200 && op.Status < 300 {
+				continue
+			}
+			errs[i] = &BulkItemError{
+				Index:      items[i].index,
+				DocumentID: items[i].id,
+				Status:     op.Status,
+				Type:       op.Error.Type,
+				Reason:     op.Error.Reason,
+			}
+		}
+	}
+	return errs, nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryableBulk(t *testing.T) {
+	connReset := errors.New("connection reset")
+	if !retryableBulk(connReset, connReset) {
+		t.Error("a failed _bulk request should be retried")
+	}
+
+	retryable := []*BulkItemError{
+		{Status: 429, Type: "es_rejected_execution_exception"},
+		{Status: 503, Type: "unavailable_shards_exception"},
+		// Index writes are blocked while a migration copies the index
+		{Status: 403, Type: "cluster_block_exception"},
+	}
+	for _, itemErr := range retryable {
+		if !retryableBulk(itemErr, nil) {
+			t.Errorf("retryableBulk(%v) = false, want true", itemErr)
+		}
+	}
+
+	permanent := []error{
+		&BulkItemError{Status: 400, Type: "mapper_parsing_exception"},
+		&BulkItemError{Status: 403, Type: "security_exception"},
+		errors.New("failed to marshal"),
+	}
+	for _, err := range permanent {
+		if retryableBulk(err, nil) {
+			t.Errorf("retryableBulk(%v) = true, want false", err)
+		}
+	}
+}
+
+func TestBulkAddReportsFlushFailuresOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		http.Error(w, `{"error": "unavailable"}`, http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ec, err := NewElasticClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bulk := ec.NewBulkIndexer(BulkOptions{MaxDocs: 1})
+
+	calls := 0
+	err = bulk.Add(context.Background(), "manga_test", "1-1", map[string]int{"image_num": 1}, func(err error) {
+		calls++
+		if err == nil {
+			t.Error("onDone(nil), want the request error")
+		}
+	})
+	if err != nil {
+		t.Errorf("Add() = %v, want the failure reported to onDone only", err)
+	}
+	if calls != 1 {
+		t.Errorf("onDone called %d times, want once", calls)
+	}
+}
+
+func TestBulkFlushWaitsForWriteBlock(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		requests++
+		if requests <= 3 {
+			w.Write([]byte(`{"errors": true, "items": [{"index": {"status": 403, "error": {"type": "cluster_block_exception", "reason": "index [manga_test_v1] blocked by: [FORBIDDEN/8/index write (api)]"}}}]}`))
+			return
+		}
+		w.Write([]byte(`{"errors": false, "items": [{"index": {"status": 201}}]}`))
+	}))
+	defer server.Close()
+
+	ec, err := NewElasticClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The block outlasts the retry budget of other failures
+	bulk := ec.NewBulkIndexer(BulkOptions{Retries: 1, RetryDelay: time.Millisecond})
+
+	var result error
+	if err := bulk.Add(context.Background(), "manga_test", "1-1", map[string]int{"image_num": 1}, func(err error) { result = err }); err != nil {
+		t.Fatal(err)
+	}
+	if err := bulk.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if result != nil || requests != 4 {
+		t.Errorf("document finished with %v after %d requests, want it indexed once the block is lifted", result, requests)
+	}
+}
@@ -2,21 +2,24 @@ package storage
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/blob"
 )
 
 type ElasticClient struct {
 	client *elasticsearch.Client
+
+	templatesMu      sync.Mutex
+	templatesApplied bool
 }
 
 func NewElasticClient(address string) (*ElasticClient, error) {
@@ -30,41 +33,17 @@ func NewElasticClient(address string) (*ElasticClient, error) {
 	return &ElasticClient{client: client}, nil
 }
 
-func (ec *ElasticClient) IndexMangaImage(indexName string, chapterID int, imageNum int, imagePath string, metadata map[string]interface{}) error {
-	// Read the image file
-	imgData, err := os.ReadFile(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to read image file: %w", err)
-	}
-
-	// Encode as Base64
-	imgBase64 := base64.StdEncoding.EncodeToString(imgData)
-
-	// Determine content type based on file extension
-	contentType := "image/jpeg"
-	if strings.HasSuffix(imagePath, ".png") {
-		contentType = "image/png"
-	} else if strings.HasSuffix(imagePath, ".webp") {
-		contentType = "image/webp"
-	}
-
-	doc := map[string]interface{}{
-		"chapter_id":    chapterID,
-		"image_num":     imageNum,
-		"image_data":    imgBase64,
-		"content_type":  contentType,
-		"downloaded_at": time.Now().UTC(),
-		"metadata":      metadata,
-	}
-
-	docJSON, err := json.Marshal(doc)
+// IndexMangaImage indexes a page. The image itself lives in the blob
+// store, the document only references it by hash.
+func (ec *ElasticClient) IndexMangaImage(indexName string, chapterID string, imageNum int, image blob.Info, metadata map[string]interface{}) error {
+	docJSON, err := json.Marshal(mangaImageDoc(chapterID, imageNum, image, metadata))
 	if err != nil {
 		return fmt.Errorf("failed to marshal document: %w", err)
 	}
 
 	req := esapi.IndexRequest{
 		Index:      indexName,
-		DocumentID: fmt.Sprintf("%d-%d", chapterID, imageNum),
+		DocumentID: pageDocumentID(chapterID, imageNum),
 		Body:       strings.NewReader(string(docJSON)),
 		Refresh:    "true",
 	}
@@ -79,10 +58,30 @@ func (ec *ElasticClient) IndexMangaImage(indexName string, chapterID int, imageN
 		return fmt.Errorf("Elasticsearch error: %s", res.String())
 	}
 
-	log.Printf("Successfully indexed image %d from chapter %d in index %s", imageNum, chapterID, indexName)
+	log.Printf("Successfully indexed image %d from chapter %s in index %s", imageNum, chapterID, indexName)
 	return nil
 }
 
+func mangaImageDoc(chapterID string, imageNum int, image blob.Info, metadata map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"chapter_id":    chapterID,
+		"image_num":     imageNum,
+		"blob_hash":     image.Hash,
+		"size":          image.Size,
+		"width":         image.Width,
+		"height":        image.Height,
+		"content_type":  image.ContentType,
+		"downloaded_at": time.Now().UTC(),
+		"metadata":      metadata,
+	}
+}
+
+// pageDocumentID keys page documents "<chapter>-<page>", which
+// GetIndexedChapters relies on.
+func pageDocumentID(chapterID string, imageNum int) string {
+	return fmt.Sprintf("%s-%d", chapterID, imageNum)
+}
+
 func (ec *ElasticClient) Ping() error {
 	req := esapi.PingRequest{}
 	res, err := req.Do(context.Background(), ec.client)
@@ -105,8 +104,22 @@ func (ec *ElasticClient) EnsureIndex(indexName string) error {
 	defer res.Body.Close()
 
 	if res.StatusCode == 404 {
+		// Indices only pick up the mappings of templates present at creation
+		if err := ec.EnsureTemplates(); err != nil {
+			return fmt.Errorf("failed to apply index templates: %w", err)
+		}
+
+		// Managed indices are versioned behind an alias so migrations can
+		// swap them without renaming
+		name, opts := indexName, []func(*esapi.IndicesCreateRequest){}
+		if t, ok := templateFor(indexName); ok {
+			name = versionedIndexName(indexName, t.Version)
+			body := fmt.Sprintf(`{"aliases": {%q: {"is_write_index": true}}}`, indexName)
+			opts = append(opts, ec.client.Indices.Create.WithBody(strings.NewReader(body)))
+		}
+
 		// Create index
-		createRes, err := ec.client.Indices.Create(indexName)
+		createRes, err := ec.client.Indices.Create(name, opts...)
 		if err != nil {
 			return err
 		}
@@ -115,15 +128,123 @@ func (ec *ElasticClient) EnsureIndex(indexName string) error {
 		if createRes.IsError() {
 			return fmt.Errorf("failed to create index: %s", createRes.String())
 		}
-		log.Printf("Created index: %s", indexName)
+		log.Printf("Created index: %s", name)
 	}
 	return nil
 }
 
-func (ec *ElasticClient) GetMangaIndexName(mangaTitle, mangaID string) string {
-	cleanTitle := strings.ToLower(strings.TrimSpace(mangaTitle))
-	cleanTitle = regexp.MustCompile(`[^a-z0-9_]+`).ReplaceAllString(cleanTitle, "_")
-	cleanTitle = strings.Trim(cleanTitle, "_")
-	return fmt.Sprintf("manga_%s_%s", cleanTitle, mangaID)
+// GetMangaIndexName names the page index of a series after its source and
+// series ID, so renamed series and failed title lookups keep their index.
+// The catalog maps titles to it through core.Manga.IndexName.
+func (ec *ElasticClient) GetMangaIndexName(source, mangaID string) string {
+	return fmt.Sprintf("manga_%s_%s", indexNamePart(source), indexNamePart(mangaID))
+}
+
+var invalidIndexChars = regexp.MustCompile(`[^a-z0-9_]+`)
 
+func indexNamePart(s string) string {
+	clean := strings.ToLower(strings.TrimSpace(s))
+	clean = invalidIndexChars.ReplaceAllString(clean, "_")
+	clean = strings.Trim(clean, "_")
+	if clean == "" {
+		return "unknown"
+	}
+	return clean
+}
+
+// GetIndexedChapters returns the chapters that have all their pages in
+// the index. Page documents are keyed "<chapter>-<page>" and record the
+// page count of their chapter, pages indexed before the count was
+// recorded make their chapter count as complete.
+func (ec *ElasticClient) GetIndexedChapters(indexName string) (map[string]bool, error) {
+	indexed := make(map[string]int)
+	expected := make(map[string]int)
+	complete := func() map[string]bool {
+		chapters := make(map[string]bool)
+		for chapter, pages := range indexed {
+			if pages >= expected[chapter] {
+				chapters[chapter] = true
+			}
+		}
+		return chapters
+	}
+
+	res, err := ec.client.Search(
+		ec.client.Search.WithContext(context.Background()),
+		ec.client.Search.WithIndex(indexName),
+		ec.client.Search.WithBody(strings.NewReader(`{"_source": ["metadata.page_count"], "query": {"match_all": {}}}`)),
+		ec.client.Search.WithSize(1000),
+		ec.client.Search.WithScroll(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search index: %w", err)
+	}
+
+	for {
+		if res.StatusCode == 404 {
+			res.Body.Close()
+			return complete(), nil
+		}
+		if res.IsError() {
+			defer res.Body.Close()
+			return nil, fmt.Errorf("Elasticsearch error: %s", res.String())
+		}
+
+		var page struct {
+			ScrollID string `json:"_scroll_id"`
+			Hits     struct {
+				Hits []struct {
+					ID     string `json:"_id"`
+					Source struct {
+						Metadata struct {
+							PageCount int `json:"page_count"`
+						} `json:"metadata"`
+					} `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		err := json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing response: %w", err)
+		}
+
+		for _, hit := range page.Hits.Hits {
+			i := strings.LastIndex(hit.ID, "-")
+			if i <= 0 {
+				continue
+			}
+			chapter := hit.ID[:i]
+			indexed[chapter]++
+			if count := hit.Source.Metadata.PageCount; count > expected[chapter] {
+				expected[chapter] = count
+			}
+		}
+
+		if len(page.Hits.Hits) == 0 || page.ScrollID == "" {
+			ec.clearScroll(page.ScrollID)
+			return complete(), nil
+		}
+
+		res, err = ec.client.Scroll(
+			ec.client.Scroll.WithScrollID(page.ScrollID),
+			ec.client.Scroll.WithScroll(time.Minute),
+		)
+		if err != nil {
+			ec.clearScroll(page.ScrollID)
+			return nil, fmt.Errorf("failed to scroll index: %w", err)
+		}
+	}
+}
+
+func (ec *ElasticClient) clearScroll(scrollID string) {
+	if scrollID == "" {
+		return
+	}
+	res, err := ec.client.ClearScroll(ec.client.ClearScroll.WithScrollID(scrollID))
+	if err != nil {
+		log.Printf("Failed to clear scroll: %v", err)
+		return
+	}
+	res.Body.Close()
 }
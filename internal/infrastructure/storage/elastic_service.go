@@ -1,75 +1,411 @@
 package storage
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
+	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/sucumbap/mangaroo/internal/core"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/blob"
+	apperrors "github.com/sucumbap/mangaroo/pkg/errors"
 )
 
-func (es *ElasticService) IndexMangaImage(indexName string, chapterID int, imageNum int, imagePath string, metadata map[string]interface{}) error {
-	return es.ElasticClient.IndexMangaImage(indexName, chapterID, imageNum, imagePath, metadata)
+// Search limits, Elasticsearch refuses to page past 10000 hits by default
+const (
+	defaultSearchSize = 20
+	maxSearchSize     = 100
+	maxResultWindow   = 10000
+	facetSize         = 50
+)
+
+func (es *ElasticService) IndexMangaImage(indexName string, chapterID string, imageNum int, image blob.Info, metadata map[string]interface{}) error {
+	return es.ElasticClient.IndexMangaImage(indexName, chapterID, imageNum, image, metadata)
 }
 
+// SearchMangaImage returns the blob hashes of the pages whose manga title,
+// chapter title or chapter number match query.
 func (es *ElasticService) SearchMangaImage(indexName string, query string) ([]string, error) {
-	// Implement this using the ElasticClient
-	// This is a stub implementation - you'll need to add actual search functionality
-	return []string{}, fmt.Errorf("not implemented")
+	body, err := json.Marshal(map[string]interface{}{
+		"size":    maxSearchSize,
+		"_source": []string{"blob_hash"},
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  query,
+				"fields": []string{"metadata.manga_title", "metadata.chapter_title", "chapter_id"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+	return es.pageHashes(indexName, string(body))
 }
 
-func (es *ElasticService) DeleteMangaImage(indexName string, chapterID int) error {
-	// Implement using ElasticClient
-	return fmt.Errorf("not implemented")
+// DeleteMangaImage removes every page of a chapter from the index. The
+// blobs stay, other pages may share them.
+func (es *ElasticService) DeleteMangaImage(indexName string, chapterID string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"chapter_id": chapterID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	refresh := true
+	req := esapi.DeleteByQueryRequest{
+		Index:   []string{indexName},
+		Body:    strings.NewReader(string(body)),
+		Refresh: &refresh,
+	}
+	if err := es.ElasticClient.do(context.Background(), req, nil); err != nil {
+		return fmt.Errorf("failed to delete chapter %s: %w", chapterID, err)
+	}
+	return nil
 }
 
-func (es *ElasticService) GetMangaImage(indexName string, chapterID int) ([]string, error) {
-	// Implement using ElasticClient
-	return []string{}, fmt.Errorf("not implemented")
+// GetMangaImage returns the blob hashes of a chapter's pages in reading
+// order.
+func (es *ElasticService) GetMangaImage(indexName string, chapterID string) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"size":    maxResultWindow,
+		"_source": []string{"blob_hash"},
+		"sort":    []interface{}{map[string]string{"image_num": "asc"}},
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"chapter_id": chapterID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+	return es.pageHashes(indexName, string(body))
+}
+
+func (es *ElasticService) pageHashes(indexName string, body string) ([]string, error) {
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source struct {
+					BlobHash string `json:"blob_hash"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	req := esapi.SearchRequest{
+		Index: []string{indexName},
+		Body:  strings.NewReader(body),
+	}
+	if err := es.ElasticClient.do(context.Background(), req, &result); err != nil {
+		return nil, fmt.Errorf("failed to search pages: %w", err)
+	}
+
+	hashes := make([]string, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		if hit.Source.BlobHash != "" {
+			hashes = append(hashes, hit.Source.BlobHash)
+		}
+	}
+	return hashes, nil
 }
 
-// Add remaining interface methods with proper implementation
 func (es *ElasticService) IndexManga(indexName string, manga core.Manga) error {
-	// Implement
-	return fmt.Errorf("not implemented")
+	if err := es.ElasticClient.EnsureIndex(indexName); err != nil {
+		return fmt.Errorf("failed to ensure index exists: %w", err)
+	}
+
+	docJSON, err := json.Marshal(newCatalogDoc(manga))
+	if err != nil {
+		return fmt.Errorf("failed to marshal manga: %w", err)
+	}
+
+	req := esapi.IndexRequest{
+		Index:      indexName,
+		DocumentID: catalogID(manga.Source, manga.ID),
+		Body:       strings.NewReader(string(docJSON)),
+		Refresh:    "true",
+	}
+	if err := es.ElasticClient.do(context.Background(), req, nil); err != nil {
+		return fmt.Errorf("failed to index manga: %w", err)
+	}
+	return nil
 }
 
-func (es *ElasticService) GetManga(indexName string, mangaID string) (core.Manga, error) {
-	// Implement
-	return core.Manga{}, fmt.Errorf("not implemented")
+func (es *ElasticService) GetManga(indexName string, source string, mangaID string) (core.Manga, error) {
+	req := esapi.GetRequest{
+		Index:      indexName,
+		DocumentID: catalogID(source, mangaID),
+	}
+
+	res, err := req.Do(context.Background(), es.ElasticClient.client)
+	if err != nil {
+		return core.Manga{}, fmt.Errorf("failed to get manga: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return core.Manga{}, apperrors.NewAppError(http.StatusNotFound, "manga not found", nil)
+	}
+	if res.IsError() {
+		return core.Manga{}, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	var result struct {
+		Source core.Manga `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return core.Manga{}, fmt.Errorf("error parsing response: %w", err)
+	}
+	return result.Source, nil
 }
 
 func (es *ElasticService) GetAllManga(indexName string) ([]core.Manga, error) {
-	// Implement
-	return []core.Manga{}, fmt.Errorf("not implemented")
+	req := esapi.SearchRequest{
+		Index: []string{indexName},
+		Body:  strings.NewReader(fmt.Sprintf(`{"size": %d, "query": {"match_all": {}}}`, maxResultWindow)),
+	}
+
+	res, err := req.Do(context.Background(), es.ElasticClient.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search manga: %w", err)
+	}
+	defer res.Body.Close()
+
+	// Nothing was saved yet
+	if res.StatusCode == 404 {
+		return []core.Manga{}, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source core.Manga `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	mangas := make([]core.Manga, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		mangas = append(mangas, hit.Source)
+	}
+	return mangas, nil
 }
 
-func (es *ElasticService) DeleteManga(indexName string, mangaID string) error {
-	// Implement
-	return fmt.Errorf("not implemented")
+func (es *ElasticService) DeleteManga(indexName string, source string, mangaID string) error {
+	req := esapi.DeleteRequest{
+		Index:      indexName,
+		DocumentID: catalogID(source, mangaID),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(context.Background(), es.ElasticClient.client)
+	if err != nil {
+		return fmt.Errorf("failed to delete manga: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return apperrors.NewAppError(http.StatusNotFound, "manga not found", nil)
+	}
+	if res.IsError() {
+		return fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+	return nil
 }
 
+// SearchManga returns the best matches for a full-text query.
 func (es *ElasticService) SearchManga(indexName string, query string) ([]core.Manga, error) {
-	// Implement
-	return []core.Manga{}, fmt.Errorf("not implemented")
+	result, err := es.Search(indexName, core.MangaQuery{Text: query})
+	if err != nil {
+		return nil, err
+	}
+
+	mangas := make([]core.Manga, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		mangas = append(mangas, hit.Manga)
+	}
+	return mangas, nil
+}
+
+// Search runs a catalog query with genre, author and status facets and
+// highlighted matches.
+func (es *ElasticService) Search(indexName string, query core.MangaQuery) (core.MangaSearchResult, error) {
+	body, err := json.Marshal(searchBody(query))
+	if err != nil {
+		return core.MangaSearchResult{}, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	req := esapi.SearchRequest{
+		Index: []string{indexName},
+		Body:  strings.NewReader(string(body)),
+	}
+	res, err := req.Do(context.Background(), es.ElasticClient.client)
+	if err != nil {
+		return core.MangaSearchResult{}, fmt.Errorf("failed to search manga: %w", err)
+	}
+	defer res.Body.Close()
+
+	result := core.MangaSearchResult{
+		Hits:   []core.MangaHit{},
+		Facets: map[string][]core.FacetCount{},
+	}
+
+	// Nothing was saved yet
+	if res.StatusCode == 404 {
+		return result, nil
+	}
+	if res.IsError() {
+		return core.MangaSearchResult{}, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Score     float64             `json:"_score"`
+				Source    core.Manga          `json:"_source"`
+				Highlight map[string][]string `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int64  `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return core.MangaSearchResult{}, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	result.Total = response.Hits.Total.Value
+	for _, hit := range response.Hits.Hits {
+		result.Hits = append(result.Hits, core.MangaHit{
+			Manga:      hit.Source,
+			Score:      hit.Score,
+			Highlights: hit.Highlight,
+		})
+	}
+	for name, agg := range response.Aggregations {
+		counts := make([]core.FacetCount, 0, len(agg.Buckets))
+		for _, bucket := range agg.Buckets {
+			counts = append(counts, core.FacetCount{Value: bucket.Key, Count: bucket.DocCount})
+		}
+		result.Facets[name] = counts
+	}
+	return result, nil
+}
+
+// searchBody builds the request for a catalog query. Filters compare
+// case-insensitively since scraped values aren't normalised.
+func searchBody(query core.MangaQuery) map[string]interface{} {
+	size := query.Size
+	if size <= 0 {
+		size = defaultSearchSize
+	}
+	if size > maxSearchSize {
+		size = maxSearchSize
+	}
+	from := query.From
+	if from < 0 {
+		from = 0
+	}
+	if from+size > maxResultWindow {
+		size = maxResultWindow - from
+		if size < 0 {
+			size = 0
+		}
+	}
+
+	must := []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}}
+	if strings.TrimSpace(query.Text) != "" {
+		must = []interface{}{map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     query.Text,
+				"fields":    []string{"title^3", "alt_titles^2", "description"},
+				"fuzziness": "AUTO",
+				"operator":  "and",
+			},
+		}}
+	}
+
+	filter := []interface{}{}
+	term := func(field, value string) {
+		filter = append(filter, map[string]interface{}{
+			"term": map[string]interface{}{
+				field: map[string]interface{}{"value": value, "case_insensitive": true},
+			},
+		})
+	}
+	for _, genre := range query.Genres {
+		term("genres", genre)
+	}
+	for _, author := range query.Authors {
+		term("authors.keyword", author)
+	}
+	if query.Status != "" {
+		term("status", query.Status)
+	}
+
+	return map[string]interface{}{
+		"from":             from,
+		"size":             size,
+		"track_total_hits": true,
+		"_source":          map[string]interface{}{"excludes": []string{"chapters"}},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   must,
+				"filter": filter,
+			},
+		},
+		"aggs": map[string]interface{}{
+			"genres":  map[string]interface{}{"terms": map[string]interface{}{"field": "genres", "size": facetSize}},
+			"authors": map[string]interface{}{"terms": map[string]interface{}{"field": "authors.keyword", "size": facetSize}},
+			"status":  map[string]interface{}{"terms": map[string]interface{}{"field": "status", "size": facetSize}},
+		},
+		"highlight": map[string]interface{}{
+			"pre_tags":  []string{"<em>"},
+			"post_tags": []string{"</em>"},
+			"fields": map[string]interface{}{
+				"title":       map[string]interface{}{"number_of_fragments": 0},
+				"alt_titles":  map[string]interface{}{"number_of_fragments": 0},
+				"description": map[string]interface{}{"fragment_size": 150, "number_of_fragments": 3},
+			},
+		},
+	}
 }
 
-func (es *ElasticService) GetMangaIndexName(mangaTitle string, mangaID string) string {
-	return es.ElasticClient.GetMangaIndexName(mangaTitle, mangaID)
+func (es *ElasticService) GetMangaIndexName(source string, mangaID string) string {
+	return es.ElasticClient.GetMangaIndexName(source, mangaID)
 }
 
 type ElasticService struct {
 	ElasticClient *ElasticClient
 }
 type ElasticServiceInterface interface {
-	IndexMangaImage(indexName string, chapterID int, imageNum int, imagePath string, metadata map[string]interface{}) error
+	IndexMangaImage(indexName string, chapterID string, imageNum int, image blob.Info, metadata map[string]interface{}) error
 	SearchMangaImage(indexName string, query string) ([]string, error)
-	DeleteMangaImage(indexName string, chapterID int) error
-	GetMangaImage(indexName string, chapterID int) ([]string, error)
+	DeleteMangaImage(indexName string, chapterID string) error
+	GetMangaImage(indexName string, chapterID string) ([]string, error)
 	IndexManga(indexName string, manga core.Manga) error
-	GetManga(indexName string, mangaID string) (core.Manga, error)
+	GetManga(indexName string, source string, mangaID string) (core.Manga, error)
 	GetAllManga(indexName string) ([]core.Manga, error)
-	DeleteManga(indexName string, mangaID string) error
+	DeleteManga(indexName string, source string, mangaID string) error
 	SearchManga(indexName string, query string) ([]core.Manga, error)
-	GetMangaIndexName(mangaTitle string, mangaID string) string
+	Search(indexName string, query core.MangaQuery) (core.MangaSearchResult, error)
+	Suggest(indexName string, prefix string, size int) ([]core.MangaSuggestion, error)
+	GetMangaIndexName(source string, mangaID string) string
 }
 
 func NewElasticService(elasticClient *ElasticClient) *ElasticService {
@@ -0,0 +1,441 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Managed indices are physical "<alias>_v<schema>" indices behind an alias
+// carrying the name the rest of mangaroo uses. Indices created before
+// aliases were introduced are concrete indices with that name instead.
+
+// IndexMigration moves an alias to an index with the current schema.
+type IndexMigration struct {
+	Alias       string `json:"alias"`
+	Index       string `json:"index"`
+	Target      string `json:"target"`
+	Template    string `json:"template"`
+	FromVersion int    `json:"from_version"`
+	ToVersion   int    `json:"to_version"`
+	Docs        int64  `json:"docs"`
+	// Legacy is set for concrete indices named like the alias, these are
+	// replaced by the alias when the migration completes
+	Legacy bool   `json:"legacy"`
+	Error  string `json:"error,omitempty"`
+}
+
+// MigrationReport lists the migrations that were planned or run.
+type MigrationReport struct {
+	DryRun     bool             `json:"dry_run"`
+	UpToDate   int              `json:"up_to_date"`
+	Migrations []IndexMigration `json:"migrations"`
+}
+
+// MigrateOptions controls a migration run.
+type MigrateOptions struct {
+	// DryRun only reports what would be migrated
+	DryRun bool
+	// KeepOld keeps the previous index instead of deleting it. Indices
+	// created before aliases are kept as <alias>_v<schema>
+	KeepOld bool
+}
+
+// MaxWriteBlock bounds how long a migration blocks writes to an index.
+// Bulk writers wait as long for the block to be lifted.
+const MaxWriteBlock = 10 * time.Minute
+
+// versionedIndexName names the physical index holding a schema version.
+func versionedIndexName(alias string, version int) string {
+	return fmt.Sprintf("%s_v%d", alias, version)
+}
+
+// templateFor returns the managed template whose patterns match an index.
+func templateFor(index string) (IndexTemplate, bool) {
+	for _, t := range IndexTemplates {
+		for _, pattern := range t.Patterns {
+			if ok, _ := path.Match(pattern, index); ok {
+				return t, true
+			}
+		}
+	}
+	return IndexTemplate{}, false
+}
+
+// Migrate brings every managed index to the schema version of its
+// template. Each index is copied into a new versioned index with the
+// reindex API while the alias keeps serving the old one, then writes to
+// the old index are blocked, documents written meanwhile are copied and
+// the alias is moved in a single atomic update.
+func (ec *ElasticClient) Migrate(ctx context.Context, opts MigrateOptions) (MigrationReport, error) {
+	report := MigrationReport{DryRun: opts.DryRun}
+
+	plan, upToDate, err := ec.PlanMigrations(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.UpToDate = upToDate
+	report.Migrations = plan
+	if opts.DryRun || len(plan) == 0 {
+		return report, nil
+	}
+
+	// New indices must pick up the current mappings
+	if err := ec.ApplyTemplates(IndexTemplates); err != nil {
+		return report, fmt.Errorf("failed to apply index templates: %w", err)
+	}
+
+	failed := 0
+	for i := range report.Migrations {
+		m := &report.Migrations[i]
+		if err := ec.migrateIndex(ctx, *m, opts); err != nil {
+			log.Printf("Migration of %s to %s failed: %v", m.Index, m.Target, err)
+			m.Error = err.Error()
+			failed++
+			continue
+		}
+		log.Printf("Migrated %s from schema %d to %d (%s)", m.Alias, m.FromVersion, m.ToVersion, m.Target)
+	}
+	if failed > 0 {
+		return report, fmt.Errorf("%d of %d index migrations failed", failed, len(report.Migrations))
+	}
+	return report, nil
+}
+
+// managedIndex is a physical index serving a logical index name.
+type managedIndex struct {
+	Alias    string
+	Index    string
+	Template IndexTemplate
+	Version  int
+	// Legacy is set when Index is a concrete index named Alias
+	Legacy bool
+}
+
+// managedIndices lists the served indices matching patterns, sorted by
+// alias.
+func (ec *ElasticClient) managedIndices(ctx context.Context, patterns []string) ([]managedIndex, error) {
+	versions, err := ec.schemaVersions(ctx, patterns)
+	if err != nil {
+		return nil, err
+	}
+	writeAliases, err := ec.writeAliases(ctx, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var indices []managedIndex
+	for index, version := range versions {
+		alias, aliased := writeAliases[index]
+		if !aliased {
+			if strings.HasPrefix(index, ".") {
+				continue
+			}
+			// Leftovers of a migration that didn't finish aren't served
+			if _, ok := templateFor(trimVersion(index)); ok && trimVersion(index) != index {
+				log.Printf("Skipping index %s, no alias points to it", index)
+				continue
+			}
+			alias = index
+		}
+
+		t, ok := templateFor(alias)
+		if !ok {
+			continue
+		}
+		indices = append(indices, managedIndex{
+			Alias:    alias,
+			Index:    index,
+			Template: t,
+			Version:  version,
+			Legacy:   !aliased,
+		})
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Alias < indices[j].Alias })
+	return indices, nil
+}
+
+// PlanMigrations lists the managed indices behind their template's schema
+// version and counts the ones that are current.
+func (ec *ElasticClient) PlanMigrations(ctx context.Context) ([]IndexMigration, int, error) {
+	var patterns []string
+	for _, t := range IndexTemplates {
+		patterns = append(patterns, t.Patterns...)
+	}
+
+	indices, err := ec.managedIndices(ctx, patterns)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var plan []IndexMigration
+	upToDate := 0
+	for _, index := range indices {
+		if index.Version >= index.Template.Version {
+			upToDate++
+			continue
+		}
+
+		docs, err := ec.countDocs(ctx, index.Index)
+		if err != nil {
+			return nil, 0, err
+		}
+		plan = append(plan, IndexMigration{
+			Alias:       index.Alias,
+			Index:       index.Index,
+			Target:      versionedIndexName(index.Alias, index.Template.Version),
+			Template:    index.Template.Name,
+			FromVersion: index.Version,
+			ToVersion:   index.Template.Version,
+			Docs:        docs,
+			Legacy:      index.Legacy,
+		})
+	}
+	return plan, upToDate, nil
+}
+
+// trimVersion strips the "_v<schema>" suffix of a versioned index.
+func trimVersion(index string) string {
+	i := strings.LastIndex(index, "_v")
+	if i < 0 || i+2 == len(index) {
+		return index
+	}
+	for _, r := range index[i+2:] {
+		if r < '0' || r > '9' {
+			return index
+		}
+	}
+	return index[:i]
+}
+
+func (ec *ElasticClient) migrateIndex(ctx context.Context, m IndexMigration, opts MigrateOptions) error {
+	t, _ := templateFor(m.Alias)
+
+	exists, err := ec.indexExists(ctx, m.Target)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := ec.do(ctx, esapi.IndicesCreateRequest{Index: m.Target}, nil); err != nil {
+			return fmt.Errorf("failed to create %s: %w", m.Target, err)
+		}
+	}
+
+	// Copy while the old index still takes writes
+	if err := ec.reindex(ctx, m.Index, m.Target, false, t.Script); err != nil {
+		return err
+	}
+
+	// Block writes and copy what was written during the first pass, bulk
+	// writers fail until the alias moves and retry against the new index
+	block := esapi.IndicesPutSettingsRequest{
+		Index: []string{m.Index},
+		Body:  strings.NewReader(`{"index": {"blocks": {"write": true}}}`),
+	}
+	if err := ec.do(ctx, block, nil); err != nil {
+		return fmt.Errorf("failed to block writes to %s: %w", m.Index, err)
+	}
+	blockedCtx, cancel := context.WithTimeout(ctx, MaxWriteBlock)
+	defer cancel()
+
+	if err := ec.reindex(blockedCtx, m.Index, m.Target, false, t.Script); err != nil {
+		ec.unblockWrites(ctx, m.Index)
+		return err
+	}
+	if t.Backfill != nil {
+		if err := t.Backfill(ec, blockedCtx, m.Target); err != nil {
+			ec.unblockWrites(ctx, m.Index)
+			return fmt.Errorf("failed to backfill %s: %w", m.Target, err)
+		}
+	}
+
+	if m.Legacy && opts.KeepOld {
+		// The alias takes the name of the concrete index, so its documents
+		// are kept in a copy named like an old versioned index
+		kept := versionedIndexName(m.Alias, m.FromVersion)
+		if err := ec.do(blockedCtx, esapi.IndicesCloneRequest{Index: m.Index, Target: kept}, nil); err != nil {
+			ec.unblockWrites(ctx, m.Index)
+			return fmt.Errorf("failed to keep %s as %s: %w", m.Index, kept, err)
+		}
+		log.Printf("Kept %s as %s", m.Index, kept)
+	}
+
+	actions := []map[string]interface{}{
+		{"add": map[string]interface{}{"index": m.Target, "alias": m.Alias, "is_write_index": true}},
+	}
+	if m.Legacy {
+		// The alias can only take the name once the concrete index is gone
+		actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": m.Index}})
+	} else {
+		actions = append(actions, map[string]interface{}{"remove": map[string]interface{}{"index": m.Index, "alias": m.Alias}})
+	}
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return fmt.Errorf("failed to marshal alias actions: %w", err)
+	}
+	if err := ec.do(blockedCtx, esapi.IndicesUpdateAliasesRequest{Body: strings.NewReader(string(body))}, nil); err != nil {
+		ec.unblockWrites(ctx, m.Index)
+		return fmt.Errorf("failed to move alias %s: %w", m.Alias, err)
+	}
+
+	if !m.Legacy && !opts.KeepOld {
+		if err := ec.do(ctx, esapi.IndicesDeleteRequest{Index: []string{m.Index}}, nil); err != nil {
+			log.Printf("Failed to delete old index %s: %v", m.Index, err)
+		}
+	}
+	return nil
+}
+
+func (ec *ElasticClient) unblockWrites(ctx context.Context, index string) {
+	unblock := esapi.IndicesPutSettingsRequest{
+		Index: []string{index},
+		Body:  strings.NewReader(`{"index": {"blocks": {"write": null}}}`),
+	}
+	if err := ec.do(ctx, unblock, nil); err != nil {
+		log.Printf("Failed to unblock writes to %s: %v", index, err)
+	}
+}
+
+// reindex copies all documents of source into dest, running script on
+// each if given. With onlyMissing set documents already in dest are left
+// alone, otherwise documents keep their version and only replace older
+// copies in dest, so a second pass copies just what changed since the
+// first.
+func (ec *ElasticClient) reindex(ctx context.Context, source, dest string, onlyMissing bool, script string) error {
+	destBody := map[string]interface{}{"index": dest}
+	body := map[string]interface{}{
+		"source": map[string]interface{}{"index": source},
+		"dest":   destBody,
+	}
+	if script != "" {
+		body["script"] = map[string]interface{}{"lang": "painless", "source": script}
+	}
+	if onlyMissing {
+		destBody["op_type"] = "create"
+	} else {
+		destBody["version_type"] = "external"
+	}
+	body["conflicts"] = "proceed"
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reindex request: %w", err)
+	}
+
+	wait := true
+	req := esapi.ReindexRequest{
+		Body:              strings.NewReader(string(bodyJSON)),
+		Refresh:           &wait,
+		WaitForCompletion: &wait,
+	}
+	var result struct {
+		Total    int64             `json:"total"`
+		Failures []json.RawMessage `json:"failures"`
+	}
+	if err := ec.do(ctx, req, &result); err != nil {
+		return fmt.Errorf("failed to reindex %s into %s: %w", source, dest, err)
+	}
+	if len(result.Failures) > 0 {
+		return fmt.Errorf("reindex of %s into %s had %d failures, first: %s", source, dest, len(result.Failures), result.Failures[0])
+	}
+	return nil
+}
+
+// schemaVersions reads the schema version recorded in each index mapping,
+// 0 for indices created without a managed template.
+func (ec *ElasticClient) schemaVersions(ctx context.Context, patterns []string) (map[string]int, error) {
+	var result map[string]struct {
+		Mappings struct {
+			Meta struct {
+				SchemaVersion int `json:"schema_version"`
+			} `json:"_meta"`
+		} `json:"mappings"`
+	}
+	ignore := true
+	req := esapi.IndicesGetMappingRequest{Index: patterns, IgnoreUnavailable: &ignore}
+	if err := ec.do(ctx, req, &result); err != nil {
+		return nil, fmt.Errorf("failed to get mappings: %w", err)
+	}
+
+	versions := make(map[string]int, len(result))
+	for index, mapping := range result {
+		versions[index] = mapping.Mappings.Meta.SchemaVersion
+	}
+	return versions, nil
+}
+
+// writeAliases maps indices to the alias they take writes for.
+func (ec *ElasticClient) writeAliases(ctx context.Context, patterns []string) (map[string]string, error) {
+	var result map[string]struct {
+		Aliases map[string]struct {
+			IsWriteIndex *bool `json:"is_write_index"`
+		} `json:"aliases"`
+	}
+	ignore := true
+	req := esapi.IndicesGetAliasRequest{Index: patterns, IgnoreUnavailable: &ignore}
+	if err := ec.do(ctx, req, &result); err != nil {
+		return nil, fmt.Errorf("failed to get aliases: %w", err)
+	}
+
+	aliases := make(map[string]string)
+	for index, entry := range result {
+		for alias, props := range entry.Aliases {
+			if props.IsWriteIndex == nil || *props.IsWriteIndex {
+				aliases[index] = alias
+			}
+		}
+	}
+	return aliases, nil
+}
+
+func (ec *ElasticClient) countDocs(ctx context.Context, index string) (int64, error) {
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	if err := ec.do(ctx, esapi.CountRequest{Index: []string{index}}, &result); err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", index, err)
+	}
+	return result.Count, nil
+}
+
+func (ec *ElasticClient) indexExists(ctx context.Context, index string) (bool, error) {
+	res, err := esapi.IndicesExistsRequest{Index: []string{index}}.Do(ctx, ec.client)
+	if err != nil {
+		return false, fmt.Errorf("failed to check index %s: %w", index, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+	return true, nil
+}
+
+// do runs a request and decodes its response into result if given.
+func (ec *ElasticClient) do(ctx context.Context, req esapi.Request, result interface{}) error {
+	res, err := req.Do(ctx, ec.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return fmt.Errorf("error parsing response: %w", err)
+	}
+	return nil
+}
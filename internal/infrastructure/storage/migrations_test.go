@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrimVersion(t *testing.T) {
+	for index, want := range map[string]string{
+		"mangaroo_manga_v3":              "mangaroo_manga",
+		"manga_mangakatana_foo_v12":      "manga_mangakatana_foo",
+		"manga_v1_foo_v2":                "manga_v1_foo",
+		"mangaroo_manga":                 "mangaroo_manga",
+		"mangaroo_manga_v":               "mangaroo_manga_v",
+		"manga_mangakatana_v2x":          "manga_mangakatana_v2x",
+		"manga_mangakatana_vinland_saga": "manga_mangakatana_vinland_saga",
+	} {
+		if got := trimVersion(index); got != want {
+			t.Errorf("trimVersion(%q) = %q, want %q", index, got, want)
+		}
+	}
+
+	for _, alias := range []string{"mangaroo_manga", "manga_mangakatana_foo"} {
+		if got := trimVersion(versionedIndexName(alias, 7)); got != alias {
+			t.Errorf("trimVersion(versionedIndexName(%q, 7)) = %q", alias, got)
+		}
+	}
+}
+
+func TestTemplateFor(t *testing.T) {
+	// An empty name means no managed template covers the index
+	for index, want := range map[string]string{
+		"manga_mangakatana_one_piece":    "mangaroo_pages",
+		"manga_mangakatana_one_piece_v2": "mangaroo_pages",
+		"mangaroo_manga":                 "mangaroo_catalog",
+		"mangaroo_manga_v2":              "mangaroo_catalog",
+		"mangaroo_subscriptions":         "",
+		".kibana":                        "",
+	} {
+		tmpl, ok := templateFor(index)
+		if ok != (want != "") || tmpl.Name != want {
+			t.Errorf("templateFor(%q) = %q, %v, want %q", index, tmpl.Name, ok, want)
+		}
+	}
+}
+
+func TestTemplateBodyVersion(t *testing.T) {
+	for _, tmpl := range IndexTemplates {
+		body, err := tmpl.body()
+		if err != nil {
+			t.Fatalf("%s: %v", tmpl.Name, err)
+		}
+
+		var parsed struct {
+			Version  int
+			Template struct {
+				Mappings struct {
+					Meta struct {
+						SchemaVersion int `json:"schema_version"`
+					} `json:"_meta"`
+				}
+			}
+		}
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatalf("%s: body is not valid JSON: %v", tmpl.Name, err)
+		}
+		if parsed.Version != tmpl.Version {
+			t.Errorf("%s: template version = %d, want %d", tmpl.Name, parsed.Version, tmpl.Version)
+		}
+		if parsed.Template.Mappings.Meta.SchemaVersion != tmpl.Version {
+			t.Errorf("%s: mappings schema_version = %d, want %d", tmpl.Name, parsed.Template.Mappings.Meta.SchemaVersion, tmpl.Version)
+		}
+	}
+}
+
+func TestMigrateLegacyIndexKeepOld(t *testing.T) {
+	var requests []string
+	var aliasActions string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/_reindex":
+			w.Write([]byte(`{"total": 3, "failures": []}`))
+		case r.URL.Path == "/_aliases":
+			body, _ := io.ReadAll(r.Body)
+			aliasActions = string(body)
+			w.Write([]byte(`{"acknowledged": true}`))
+		default:
+			w.Write([]byte(`{"acknowledged": true}`))
+		}
+	}))
+	defer server.Close()
+
+	ec, err := NewElasticClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := IndexMigration{
+		Alias:       "manga_mangakatana_foo",
+		Index:       "manga_mangakatana_foo",
+		Target:      "manga_mangakatana_foo_v2",
+		Template:    "mangaroo_pages",
+		FromVersion: 0,
+		ToVersion:   2,
+		Legacy:      true,
+	}
+	if err := ec.migrateIndex(context.Background(), m, MigrateOptions{KeepOld: true}); err != nil {
+		t.Fatalf("migrateIndex: %v", err)
+	}
+
+	clone := "PUT /manga_mangakatana_foo/_clone/manga_mangakatana_foo_v0"
+	cloned := -1
+	for i, request := range requests {
+		if request == clone {
+			cloned = i
+		}
+		if request == "POST /_aliases" && cloned < 0 {
+			t.Errorf("alias moved before %s was kept", m.Index)
+		}
+	}
+	if cloned < 0 {
+		t.Errorf("requests = %v, want %s", requests, clone)
+	}
+	if !strings.Contains(aliasActions, `"remove_index"`) {
+		t.Errorf("alias actions = %s, want the concrete index replaced by the alias", aliasActions)
+	}
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// SourceResolver returns the name of the source serving a series URL and
+// the series ID it derives from the URL.
+type SourceResolver func(mangaURL string) (source string, mangaID string, err error)
+
+// IndexRepair merges a page index that isn't named after its series'
+// source and ID, such as manga_unknown_<id> or an older title based
+// name, into the series' canonical index.
+type IndexRepair struct {
+	Alias    string `json:"alias"`
+	Index    string `json:"index"`
+	Target   string `json:"target"`
+	MangaID  string `json:"manga_id"`
+	MangaURL string `json:"manga_url"`
+	Source   string `json:"source"`
+	Docs     int64  `json:"docs"`
+	Error    string `json:"error,omitempty"`
+}
+
+// UnresolvedIndex is a page index whose series couldn't be determined.
+type UnresolvedIndex struct {
+	Index  string `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// RepairReport lists the repairs that were planned or run.
+type RepairReport struct {
+	DryRun     bool              `json:"dry_run"`
+	Canonical  int               `json:"canonical"`
+	Repairs    []IndexRepair     `json:"repairs"`
+	Unresolved []UnresolvedIndex `json:"unresolved"`
+}
+
+// RepairOptions controls a repair run.
+type RepairOptions struct {
+	// DryRun only reports what would be merged
+	DryRun bool
+	// KeepOld keeps the merged indices instead of deleting them
+	KeepOld bool
+}
+
+// RepairIndices finds page indices not named by GetMangaIndexName and
+// merges their pages into the canonical index of their series. Pages
+// already present there are kept. The series is resolved from the URL in
+// the page metadata, indices mixing several series are left alone.
+func (ec *ElasticClient) RepairIndices(ctx context.Context, resolve SourceResolver, opts RepairOptions) (RepairReport, error) {
+	report := RepairReport{DryRun: opts.DryRun}
+
+	indices, err := ec.managedIndices(ctx, []string{"manga_*"})
+	if err != nil {
+		return report, err
+	}
+
+	for _, index := range indices {
+		repair, reason, err := ec.planRepair(ctx, index, resolve)
+		if err != nil {
+			return report, err
+		}
+		if reason != "" {
+			report.Unresolved = append(report.Unresolved, UnresolvedIndex{Index: index.Index, Reason: reason})
+			continue
+		}
+		if repair.Target == index.Alias {
+			report.Canonical++
+			continue
+		}
+		report.Repairs = append(report.Repairs, repair)
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	failed := 0
+	for i := range report.Repairs {
+		r := &report.Repairs[i]
+		if err := ec.mergeIndex(ctx, *r, opts); err != nil {
+			log.Printf("Merging %s into %s failed: %v", r.Index, r.Target, err)
+			r.Error = err.Error()
+			failed++
+			continue
+		}
+		log.Printf("Merged %d pages of %s into %s", r.Docs, r.Index, r.Target)
+	}
+	if failed > 0 {
+		return report, fmt.Errorf("%d of %d index repairs failed", failed, len(report.Repairs))
+	}
+	return report, nil
+}
+
+// planRepair determines the canonical index of a page index. A non-empty
+// reason explains why the series couldn't be determined.
+func (ec *ElasticClient) planRepair(ctx context.Context, index managedIndex, resolve SourceResolver) (IndexRepair, string, error) {
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source struct {
+					Metadata struct {
+						MangaID  string `json:"manga_id"`
+						MangaURL string `json:"manga_url"`
+					} `json:"metadata"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	search := esapi.SearchRequest{
+		Index: []string{index.Index},
+		Body:  strings.NewReader(`{"size": 1, "_source": ["metadata.manga_id", "metadata.manga_url"], "query": {"match_all": {}}}`),
+	}
+	if err := ec.do(ctx, search, &result); err != nil {
+		return IndexRepair{}, "", fmt.Errorf("failed to sample %s: %w", index.Index, err)
+	}
+	if len(result.Hits.Hits) == 0 {
+		return IndexRepair{}, "index is empty", nil
+	}
+
+	metadata := result.Hits.Hits[0].Source.Metadata
+	if metadata.MangaID == "" || metadata.MangaURL == "" {
+		return IndexRepair{}, "pages have no manga_id or manga_url metadata", nil
+	}
+	source, mangaID, err := resolve(metadata.MangaURL)
+	if err != nil {
+		return IndexRepair{}, fmt.Sprintf("no series for %s: %v", metadata.MangaURL, err), nil
+	}
+
+	// Indices created before the templates were mapped dynamically, their
+	// URLs are text and only the keyword subfield holds the exact value
+	urlField, err := ec.keywordField(ctx, index.Index, "metadata.manga_url")
+	if err != nil {
+		return IndexRepair{}, "", err
+	}
+	if urlField == "" {
+		return IndexRepair{}, "metadata.manga_url has no keyword mapping", nil
+	}
+
+	// Pages of an unknown series ID may come from several series
+	mixed, err := ec.countQuery(ctx, index.Index, map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must_not": map[string]interface{}{
+				"term": map[string]interface{}{urlField: metadata.MangaURL},
+			},
+		},
+	})
+	if err != nil {
+		return IndexRepair{}, "", err
+	}
+	if mixed > 0 {
+		return IndexRepair{}, fmt.Sprintf("%d pages belong to other series than %s", mixed, metadata.MangaURL), nil
+	}
+
+	docs, err := ec.countDocs(ctx, index.Index)
+	if err != nil {
+		return IndexRepair{}, "", err
+	}
+	return IndexRepair{
+		Alias:    index.Alias,
+		Index:    index.Index,
+		Target:   ec.GetMangaIndexName(source, mangaID),
+		MangaID:  mangaID,
+		MangaURL: metadata.MangaURL,
+		Source:   source,
+		Docs:     docs,
+	}, "", nil
+}
+
+func (ec *ElasticClient) mergeIndex(ctx context.Context, r IndexRepair, opts RepairOptions) error {
+	if err := ec.EnsureIndex(r.Target); err != nil {
+		return fmt.Errorf("failed to ensure %s exists: %w", r.Target, err)
+	}
+	if err := ec.reindex(ctx, r.Index, r.Target, true, ""); err != nil {
+		return err
+	}
+	if opts.KeepOld {
+		return nil
+	}
+	if err := ec.do(ctx, esapi.IndicesDeleteRequest{Index: []string{r.Index}}, nil); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", r.Index, err)
+	}
+	return nil
+}
+
+// keywordField returns the field holding the exact values of field in
+// index, either field itself or a keyword subfield. It is empty when
+// neither is mapped.
+func (ec *ElasticClient) keywordField(ctx context.Context, index, field string) (string, error) {
+	var result map[string]struct {
+		Mappings map[string]struct {
+			Mapping map[string]struct {
+				Type   string `json:"type"`
+				Fields map[string]struct {
+					Type string `json:"type"`
+				} `json:"fields"`
+			} `json:"mapping"`
+		} `json:"mappings"`
+	}
+	req := esapi.IndicesGetFieldMappingRequest{Index: []string{index}, Fields: []string{field}}
+	if err := ec.do(ctx, req, &result); err != nil {
+		return "", fmt.Errorf("failed to get the mapping of %s: %w", index, err)
+	}
+
+	for _, mapping := range result[index].Mappings[field].Mapping {
+		if mapping.Type == "keyword" {
+			return field, nil
+		}
+		for name, sub := range mapping.Fields {
+			if sub.Type == "keyword" {
+				return field + "." + name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func (ec *ElasticClient) countQuery(ctx context.Context, index string, query map[string]interface{}) (int64, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": query})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	req := esapi.CountRequest{Index: []string{index}, Body: strings.NewReader(string(body))}
+	if err := ec.do(ctx, req, &result); err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", index, err)
+	}
+	return result.Count, nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const seriesURL = "https://mangakatana.com/manga/foo.123"
+
+// fakePageIndex serves a page index holding 12 pages of seriesURL whose
+// manga_url field is mapped by mapping. Like Elasticsearch, a term query
+// only matches the URL on a keyword field.
+func fakePageIndex(t *testing.T, index, exactField, mapping string) *ElasticClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+
+		switch r.URL.Path {
+		case "/" + index + "/_search":
+			fmt.Fprintf(w, `{"hits": {"hits": [{"_source": {"metadata": {"manga_id": "unknown", "manga_url": %q}}}]}}`, seriesURL)
+		case "/" + index + "/_mapping/field/metadata.manga_url":
+			fmt.Fprintf(w, `{%q: {"mappings": {"metadata.manga_url": {"full_name": "metadata.manga_url", "mapping": {"manga_url": %s}}}}}`, index, mapping)
+		case "/" + index + "/_count":
+			count := 12
+			if strings.Contains(string(body), fmt.Sprintf(`"must_not":{"term":{%q:`, exactField)) {
+				count = 0
+			}
+			fmt.Fprintf(w, `{"count": %d}`, count)
+		default:
+			http.Error(w, `{"error": "unexpected request"}`, http.StatusBadRequest)
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	ec, err := NewElasticClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ec
+}
+
+func TestPlanRepairResolvesSeries(t *testing.T) {
+	resolve := func(string) (string, string, error) { return "mangakatana", "foo.123", nil }
+
+	cases := map[string]struct {
+		exactField string
+		mapping    string
+	}{
+		"dynamic mapping":  {"metadata.manga_url.keyword", `{"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}}`},
+		"template mapping": {"metadata.manga_url", `{"type": "keyword"}`},
+	}
+	for name, c := range cases {
+		index := "manga_unknown_foo_123"
+		ec := fakePageIndex(t, index, c.exactField, c.mapping)
+
+		repair, reason, err := ec.planRepair(context.Background(), managedIndex{Alias: index, Index: index, Legacy: true}, resolve)
+		if err != nil || reason != "" {
+			t.Errorf("%s: planRepair = %q, %v, want a repair", name, reason, err)
+			continue
+		}
+		if repair.Target != "manga_mangakatana_foo_123" || repair.Docs != 12 {
+			t.Errorf("%s: repair targets %s with %d docs, want manga_mangakatana_foo_123 with 12", name, repair.Target, repair.Docs)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sucumbap/mangaroo/internal/core"
+)
+
+// encodedSearch returns the search body as Elasticsearch receives it.
+func encodedSearch(t *testing.T, query core.MangaQuery) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(searchBody(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestSearchBodyPaging(t *testing.T) {
+	cases := []struct {
+		query      core.MangaQuery
+		from, size int
+	}{
+		{core.MangaQuery{}, 0, defaultSearchSize},
+		{core.MangaQuery{From: 40, Size: 10}, 40, 10},
+		{core.MangaQuery{Size: 500}, 0, maxSearchSize},
+		{core.MangaQuery{From: -5, Size: 10}, 0, 10},
+		// Pages are clipped to the end of the result window
+		{core.MangaQuery{From: 9990, Size: 50}, 9990, 10},
+		{core.MangaQuery{From: 20000, Size: 50}, 20000, 0},
+	}
+	for _, c := range cases {
+		body := encodedSearch(t, c.query)
+		if body["from"] != float64(c.from) || body["size"] != float64(c.size) {
+			t.Errorf("from=%d size=%d: body has from=%v size=%v, want %d %d",
+				c.query.From, c.query.Size, body["from"], body["size"], c.from, c.size)
+		}
+	}
+}
+
+func TestSearchBodyQuery(t *testing.T) {
+	boolQuery := func(body map[string]interface{}) map[string]interface{} {
+		return body["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	}
+
+	for text, want := range map[string]string{"": "match_all", "   ": "match_all", "kyojin": "multi_match"} {
+		must := boolQuery(encodedSearch(t, core.MangaQuery{Text: text}))["must"].([]interface{})
+		if len(must) != 1 || must[0].(map[string]interface{})[want] == nil {
+			t.Errorf("Text %q: must = %v, want a single %s", text, must, want)
+		}
+	}
+
+	filter, ok := boolQuery(encodedSearch(t, core.MangaQuery{}))["filter"].([]interface{})
+	if !ok || len(filter) != 0 {
+		t.Errorf("filter without facets = %v, want an empty list", filter)
+	}
+
+	query := core.MangaQuery{Genres: []string{"Action", "Drama"}, Authors: []string{"Isayama"}, Status: "Completed"}
+	var fields []string
+	for _, f := range boolQuery(encodedSearch(t, query))["filter"].([]interface{}) {
+		for field, term := range f.(map[string]interface{})["term"].(map[string]interface{}) {
+			if term.(map[string]interface{})["case_insensitive"] != true {
+				t.Errorf("term on %s is case sensitive", field)
+			}
+			fields = append(fields, field)
+		}
+	}
+	if want := []string{"genres", "genres", "authors.keyword", "status"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("filter fields = %v, want %v", fields, want)
+	}
+}
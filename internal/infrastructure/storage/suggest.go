@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sucumbap/mangaroo/internal/core"
+)
+
+// Suggestion weights, full titles rank above matches inside a title
+const (
+	titleWeight    = 10
+	altTitleWeight = 5
+	wordWeight     = 1
+	maxSuggestSize = 50
+)
+
+type suggestInput struct {
+	Input  []string `json:"input"`
+	Weight int      `json:"weight"`
+}
+
+// catalogDoc is a catalog document, the series plus the inputs of its
+// title completions.
+type catalogDoc struct {
+	core.Manga
+	Suggest []suggestInput `json:"suggest,omitempty"`
+}
+
+func newCatalogDoc(manga core.Manga) catalogDoc {
+	return catalogDoc{Manga: manga, Suggest: suggestInputs(manga)}
+}
+
+// suggestInputs lists the titles a series is suggested for. Completions
+// only match from the start of an input, so titles are also added from
+// each later word on to find "kyojin" in "Shingeki no Kyojin".
+func suggestInputs(manga core.Manga) []suggestInput {
+	var inputs []suggestInput
+	var words []string
+	add := func(title string, weight int) {
+		title = strings.TrimSpace(title)
+		if title == "" {
+			return
+		}
+		inputs = append(inputs, suggestInput{Input: []string{title}, Weight: weight})
+
+		fields := strings.Fields(title)
+		for i := 1; i < len(fields); i++ {
+			words = append(words, strings.Join(fields[i:], " "))
+		}
+	}
+
+	add(manga.Title, titleWeight)
+	for _, alt := range manga.AltTitles {
+		add(alt, altTitleWeight)
+	}
+	if len(words) > 0 {
+		inputs = append(inputs, suggestInput{Input: words, Weight: wordWeight})
+	}
+	return inputs
+}
+
+// backfillSuggest saves every series of a catalog index again through
+// newCatalogDoc, so series saved before suggestions existed get their
+// inputs. Migrations run it before the alias moves to the index.
+func (ec *ElasticClient) backfillSuggest(ctx context.Context, index string) error {
+	bulk := ec.NewBulkIndexer(BulkOptions{})
+	failed := 0
+	onDone := func(err error) {
+		if err != nil {
+			failed++
+		}
+	}
+
+	var page struct {
+		ScrollID string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []struct {
+				ID     string     `json:"_id"`
+				Source core.Manga `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	search := esapi.SearchRequest{
+		Index:  []string{index},
+		Body:   strings.NewReader(`{"size": 500, "query": {"match_all": {}}}`),
+		Scroll: time.Minute,
+	}
+	if err := ec.do(ctx, search, &page); err != nil {
+		return fmt.Errorf("failed to read %s: %w", index, err)
+	}
+	defer func() { ec.clearScroll(page.ScrollID) }()
+
+	for len(page.Hits.Hits) > 0 {
+		for _, hit := range page.Hits.Hits {
+			if err := bulk.Add(ctx, index, hit.ID, newCatalogDoc(hit.Source), onDone); err != nil {
+				return err
+			}
+		}
+
+		scrollID := page.ScrollID
+		page.ScrollID = ""
+		page.Hits.Hits = nil
+		if err := ec.do(ctx, esapi.ScrollRequest{ScrollID: scrollID, Scroll: time.Minute}, &page); err != nil {
+			page.ScrollID = scrollID
+			return fmt.Errorf("failed to scroll %s: %w", index, err)
+		}
+	}
+	if err := bulk.Flush(ctx); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to add suggestions to %d series in %s", failed, index)
+	}
+	return ec.do(ctx, esapi.IndicesRefreshRequest{Index: []string{index}}, nil)
+}
+
+// Suggest completes a partial, possibly misspelled title. Every series is
+// returned at most once, ordered by score.
+func (es *ElasticService) Suggest(indexName string, prefix string, size int) ([]core.MangaSuggestion, error) {
+	if size <= 0 || size > maxSuggestSize {
+		size = maxSuggestSize
+	}
+
+	// A series has several inputs, ask for more options to fill size
+	// after dropping duplicates
+	body, err := json.Marshal(map[string]interface{}{
+		"size":    0,
+		"_source": []string{"id", "title", "source", "cover_hash", "cover_thumb_hash"},
+		"suggest": map[string]interface{}{
+			"titles": map[string]interface{}{
+				"prefix": prefix,
+				"completion": map[string]interface{}{
+					"field":           "suggest",
+					"size":            size * 3,
+					"skip_duplicates": true,
+					"fuzzy":           map[string]interface{}{"fuzziness": "AUTO"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	req := esapi.SearchRequest{
+		Index: []string{indexName},
+		Body:  strings.NewReader(string(body)),
+	}
+	res, err := req.Do(context.Background(), es.ElasticClient.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get suggestions: %w", err)
+	}
+	defer res.Body.Close()
+
+	// Nothing was saved yet
+	if res.StatusCode == 404 {
+		return []core.MangaSuggestion{}, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	var result struct {
+		Suggest struct {
+			Titles []struct {
+				Options []struct {
+					ID     string     `json:"_id"`
+					Score  float64    `json:"_score"`
+					Source core.Manga `json:"_source"`
+				} `json:"options"`
+			} `json:"titles"`
+		} `json:"suggest"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	suggestions := []core.MangaSuggestion{}
+	seen := make(map[string]bool)
+	for _, entry := range result.Suggest.Titles {
+		for _, option := range entry.Options {
+			if seen[option.ID] || len(suggestions) == size {
+				continue
+			}
+			seen[option.ID] = true
+
+			thumbnail := option.Source.CoverThumbHash
+			if thumbnail == "" {
+				thumbnail = option.Source.CoverHash
+			}
+			suggestions = append(suggestions, core.MangaSuggestion{
+				ID:            option.Source.ID,
+				Title:         option.Source.Title,
+				Source:        option.Source.Source,
+				Score:         option.Score,
+				ThumbnailHash: thumbnail,
+			})
+		}
+	}
+	return suggestions, nil
+}
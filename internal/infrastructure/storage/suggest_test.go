@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sucumbap/mangaroo/internal/core"
+)
+
+func TestSuggestInputs(t *testing.T) {
+	if got := suggestInputs(core.Manga{Title: "  "}); got != nil {
+		t.Errorf("suggestInputs of a blank title = %+v, want none", got)
+	}
+
+	got := suggestInputs(core.Manga{Title: "Berserk"})
+	want := []suggestInput{{Input: []string{"Berserk"}, Weight: titleWeight}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suggestInputs(Berserk) = %+v, want %+v", got, want)
+	}
+
+	// Later words of every title are grouped into a single low weight input
+	got = suggestInputs(core.Manga{
+		Title:     "Shingeki no Kyojin",
+		AltTitles: []string{" Attack on Titan ", ""},
+	})
+	want = []suggestInput{
+		{Input: []string{"Shingeki no Kyojin"}, Weight: titleWeight},
+		{Input: []string{"Attack on Titan"}, Weight: altTitleWeight},
+		{Input: []string{"no Kyojin", "Kyojin", "on Titan", "Titan"}, Weight: wordWeight},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suggestInputs(Shingeki no Kyojin) = %+v, want %+v", got, want)
+	}
+}
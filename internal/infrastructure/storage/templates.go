@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// IndexTemplate is an index template managed by mangaroo. Version is
+// stored as the template version and in the mappings' _meta, so every
+// index records the schema it was created with.
+type IndexTemplate struct {
+	Name     string
+	Patterns []string
+	Version  int
+	Settings string
+	Mappings string
+	// Script is a painless script run on every document a migration
+	// copies into an index of the template, it brings older documents up
+	// to the current schema
+	Script string
+	// Backfill fills in fields of a migrated index that Script can't
+	// compute, before the alias moves to it
+	Backfill func(ec *ElasticClient, ctx context.Context, index string) error
+}
+
+// IndexTemplates are applied at startup. Bump Version whenever Settings or
+// Mappings change, indices that already exist keep their old mapping.
+var IndexTemplates = []IndexTemplate{
+	{
+		Name:     "mangaroo_pages",
+		Patterns: []string{"manga_*"},
+		Version:  2,
+		Settings: `{"number_of_shards": 1}`,
+		Mappings: `{
+			"dynamic": false,
+			"properties": {
+				"chapter_id":    {"type": "keyword"},
+				"image_num":     {"type": "integer"},
+				"blob_hash":     {"type": "keyword", "index": false},
+				"image_data":    {"type": "binary"},
+				"size":          {"type": "long"},
+				"width":         {"type": "integer"},
+				"height":        {"type": "integer"},
+				"content_type":  {"type": "keyword"},
+				"downloaded_at": {"type": "date"},
+				"metadata": {
+					"properties": {
+						"manga_url":     {"type": "keyword"},
+						"manga_title":   {"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+						"manga_id":      {"type": "keyword"},
+						"chapter_num":   {"type": "keyword"},
+						"chapter_title": {"type": "text"},
+						"chapter_url":   {"type": "keyword"},
+						"image_index":   {"type": "integer"},
+						"page_count":    {"type": "integer"}
+					}
+				}
+			}
+		}`,
+	},
+	{
+		Name:     "mangaroo_catalog",
+		Patterns: []string{"mangaroo_manga", "mangaroo_manga_v*"},
+		Version:  5,
+		Settings: `{"number_of_shards": 1}`,
+		Mappings: `{
+			"dynamic": false,
+			"properties": {
+				"id":          {"type": "keyword"},
+				"title":       {"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+				"alt_titles":  {"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+				"url":         {"type": "keyword"},
+				"source":      {"type": "keyword"},
+				"status":      {"type": "keyword"},
+				"description": {"type": "text"},
+				"authors":     {"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+				"genres":      {"type": "keyword"},
+				"cover_url":   {"type": "keyword", "index": false},
+				"cover_hash":  {"type": "keyword", "index": false},
+				"cover_thumb_hash": {"type": "keyword", "index": false},
+				"chapters":    {"type": "object", "enabled": false},
+				"index_name":  {"type": "keyword"},
+				"updated_at":  {"type": "date"},
+				"suggest":     {"type": "completion", "max_input_length": 100}
+			}
+		}`,
+		// Series were keyed by their ID alone, which isn't unique across
+		// sources
+		Script:   `if (ctx._source.source != null && ctx._source.id != null) { ctx._id = ctx._source.source + ':' + ctx._source.id }`,
+		Backfill: (*ElasticClient).backfillSuggest,
+	},
+}
+
+// body builds the _index_template request body.
+func (t IndexTemplate) body() ([]byte, error) {
+	var settings, mappings map[string]interface{}
+	if err := json.Unmarshal([]byte(t.Settings), &settings); err != nil {
+		return nil, fmt.Errorf("invalid settings for template %s: %w", t.Name, err)
+	}
+	if err := json.Unmarshal([]byte(t.Mappings), &mappings); err != nil {
+		return nil, fmt.Errorf("invalid mappings for template %s: %w", t.Name, err)
+	}
+	meta := map[string]interface{}{
+		"managed_by":     "mangaroo",
+		"template":       t.Name,
+		"schema_version": t.Version,
+	}
+	mappings["_meta"] = meta
+
+	return json.Marshal(map[string]interface{}{
+		"index_patterns": t.Patterns,
+		"version":        t.Version,
+		"priority":       100,
+		"_meta":          meta,
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": mappings,
+		},
+	})
+}
+
+// EnsureTemplates applies IndexTemplates until it succeeds once.
+func (ec *ElasticClient) EnsureTemplates() error {
+	ec.templatesMu.Lock()
+	defer ec.templatesMu.Unlock()
+
+	if ec.templatesApplied {
+		return nil
+	}
+	if err := ec.ApplyTemplates(IndexTemplates); err != nil {
+		return err
+	}
+	ec.templatesApplied = true
+	return nil
+}
+
+// ApplyTemplates installs the templates that are missing or older than
+// the ones given.
+func (ec *ElasticClient) ApplyTemplates(templates []IndexTemplate) error {
+	for _, t := range templates {
+		current, err := ec.templateVersion(t.Name)
+		if err != nil {
+			return err
+		}
+		if current >= t.Version {
+			if current > t.Version {
+				log.Printf("Index template %s is at version %d, newer than %d", t.Name, current, t.Version)
+			}
+			continue
+		}
+
+		body, err := t.body()
+		if err != nil {
+			return err
+		}
+		req := esapi.IndicesPutIndexTemplateRequest{
+			Name: t.Name,
+			Body: strings.NewReader(string(body)),
+		}
+		res, err := req.Do(context.Background(), ec.client)
+		if err != nil {
+			return fmt.Errorf("failed to put index template %s: %w", t.Name, err)
+		}
+		res.Body.Close()
+		if res.IsError() {
+			return fmt.Errorf("Elasticsearch error: %s", res.String())
+		}
+		log.Printf("Applied index template %s version %d (was %d)", t.Name, t.Version, current)
+	}
+	return nil
+}
+
+// templateVersion returns the installed version of a template, 0 if it
+// doesn't exist.
+func (ec *ElasticClient) templateVersion(name string) (int, error) {
+	req := esapi.IndicesGetIndexTemplateRequest{Name: name}
+	res, err := req.Do(context.Background(), ec.client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get index template %s: %w", name, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return 0, nil
+	}
+	if res.IsError() {
+		return 0, fmt.Errorf("Elasticsearch error: %s", res.String())
+	}
+
+	var result struct {
+		IndexTemplates []struct {
+			IndexTemplate struct {
+				Version int `json:"version"`
+			} `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("error parsing response: %w", err)
+	}
+	if len(result.IndexTemplates) == 0 {
+		return 0, nil
+	}
+	return result.IndexTemplates[0].IndexTemplate.Version, nil
+}
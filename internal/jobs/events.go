@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"sync"
+
+	"github.com/sucumbap/mangaroo/internal/core"
+)
+
+const (
+	// eventHistorySize bounds how many past events are replayed to a
+	// subscriber that connects after the job started.
+	eventHistorySize = 500
+	// subscriberBuffer is the channel size per subscriber. Events are
+	// dropped for subscribers that fall further behind than this.
+	subscriberBuffer = 256
+)
+
+// eventStream fans the progress events of a single job out to any number
+// of subscribers and keeps a bounded history for late joiners.
+type eventStream struct {
+	mu          sync.Mutex
+	history     []core.ProgressEvent
+	subscribers map[chan core.ProgressEvent]struct{}
+	closed      bool
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{
+		subscribers: make(map[chan core.ProgressEvent]struct{}),
+	}
+}
+
+// publish records the event and delivers it without blocking the
+// downloader.
+func (s *eventStream) publish(event core.ProgressEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.history = append(s.history, event)
+	if len(s.history) > eventHistorySize {
+		s.history = s.history[len(s.history)-eventHistorySize:]
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// subscribe returns the events published so far and a channel for the
+// following ones. The channel is closed once the stream is closed or
+// the returned cancel function is called.
+func (s *eventStream) subscribe() ([]core.ProgressEvent, <-chan core.ProgressEvent, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := make([]core.ProgressEvent, len(s.history))
+	copy(history, s.history)
+
+	ch := make(chan core.ProgressEvent, subscriberBuffer)
+	if s.closed {
+		close(ch)
+		return history, ch, func() {}
+	}
+	s.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+	return history, ch, cancel
+}
+
+// close ends the stream for all current subscribers.
+func (s *eventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
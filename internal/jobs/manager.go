@@ -0,0 +1,595 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/sucumbap/mangaroo/internal/core"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/blob"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/browser"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/client"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/ratelimit"
+	"github.com/sucumbap/mangaroo/internal/infrastructure/storage"
+	"github.com/sucumbap/mangaroo/pkg/config"
+)
+
+// ErrQueueFull is returned by Submit when no more jobs can be queued.
+var ErrQueueFull = fmt.Errorf("job queue is full")
+
+// ErrJobActive is returned by Submit when an unfinished job already
+// downloads the series. Both would write to the same chapter folders.
+var ErrJobActive = fmt.Errorf("a job for this series is already queued or running")
+
+// Manager runs manga downloads in the background and keeps track of
+// their state so clients can poll for progress.
+type Manager struct {
+	config     *config.Config
+	elastic    *storage.ElasticClient
+	repository core.MangaRepository
+	sources    *client.Registry
+	store      *Store
+	hosts      *client.HostLimiter
+	limiter    *ratelimit.Limiter
+	browsers   *browser.Pool
+	blobs      blob.Store
+
+	mu     sync.RWMutex
+	jobs   map[string]*core.Job
+	events map[string]*eventStream
+	queue  chan string
+	// seq numbers job snapshots in the order of the changes, under mu
+	seq uint64
+
+	// persistMu guards written, the seq of each job's stored snapshot
+	persistMu sync.Mutex
+	written   map[string]uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type ManagerInterface interface {
+	Start()
+	Submit(mangaURL string, mode core.JobMode) (core.Job, error)
+	Get(id string) (core.Job, bool)
+	ValidateURL(mangaURL string) error
+	Subscribe(id string) ([]core.ProgressEvent, <-chan core.ProgressEvent, func(), bool)
+	Shutdown(ctx context.Context) error
+}
+
+func NewManager(cfg *config.Config, elastic *storage.ElasticClient, repository core.MangaRepository, sources *client.Registry, store *Store, blobs blob.Store) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	queueSize := cfg.Jobs.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+
+	limiter := ratelimit.New(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
+
+	userAgent := cfg.Browser.UserAgent
+	if userAgent == "" {
+		userAgent = cfg.Downloader.UserAgent
+	}
+	browsers := browser.NewPool(browser.PoolOptions{
+		Instances:       cfg.Browser.Instances,
+		TabsPerInstance: cfg.Browser.Tabs,
+		Headless:        cfg.Browser.Headless,
+		UserAgent:       userAgent,
+		UserDataDir:     cfg.Browser.UserDataDir,
+		LeaseTimeout:    cfg.Browser.LeaseTimeout,
+		MaxLease:        cfg.Browser.MaxLease,
+		MaxUses:         cfg.Browser.MaxUses,
+		Limiter:         limiter,
+	})
+
+	return &Manager{
+		config:     cfg,
+		elastic:    elastic,
+		repository: repository,
+		sources:    sources,
+		store:      store,
+		hosts:      client.NewHostLimiter(cfg.Downloader.HostConcurrency),
+		limiter:    limiter,
+		browsers:   browsers,
+		blobs:      blobs,
+		jobs:       make(map[string]*core.Job),
+		events:     make(map[string]*eventStream),
+		queue:      make(chan string, queueSize),
+		written:    make(map[string]uint64),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start restores persisted jobs, requeues the unfinished ones and
+// launches the worker goroutines that execute queued jobs.
+func (m *Manager) Start() {
+	workers := m.config.Jobs.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	log.Printf("Job manager started with %d workers", workers)
+
+	m.restore()
+}
+
+// restore loads the jobs of previous runs. Finished jobs stay queryable
+// until they exceed the retention period, unfinished ones resume from
+// their checkpoint.
+func (m *Manager) restore() {
+	if m.store == nil {
+		return
+	}
+
+	stored, err := m.store.LoadAll()
+	if err != nil {
+		log.Printf("Failed to restore jobs: %v", err)
+		return
+	}
+
+	var resume []string
+	for _, job := range stored {
+		job := job
+		stream := newEventStream()
+
+		if job.Finished() {
+			if job.FinishedAt != nil && time.Since(*job.FinishedAt) > m.config.Jobs.Retention {
+				if err := m.store.Delete(job.ID); err != nil {
+					log.Printf("Failed to prune job %s: %v", job.ID, err)
+				}
+				continue
+			}
+			stream.close()
+		} else {
+			job.State = core.JobQueued
+			resume = append(resume, job.ID)
+		}
+
+		m.mu.Lock()
+		m.jobs[job.ID] = &job
+		m.events[job.ID] = stream
+		m.mu.Unlock()
+	}
+
+	if len(resume) == 0 {
+		return
+	}
+	log.Printf("Resuming %d unfinished jobs", len(resume))
+
+	// More jobs than queue slots may be waiting, so don't block Start
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for _, id := range resume {
+			select {
+			case m.queue <- id:
+			case <-m.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Submit queues a download of mangaURL and returns the new job. It fails
+// with client.ErrUnsupportedSource when no source handles the URL, with
+// client.ErrInvalidSeriesURL when the URL isn't a series page and with
+// ErrJobActive when the series is being downloaded already, in which case
+// the unfinished job is returned along with the error.
+func (m *Manager) Submit(mangaURL string, mode core.JobMode) (core.Job, error) {
+	source, err := m.sources.ForURL(mangaURL)
+	if err != nil {
+		return core.Job{}, err
+	}
+	mangaID, err := source.SeriesID(mangaURL)
+	if err != nil {
+		return core.Job{}, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return core.Job{}, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	job := &core.Job{
+		ID:        id,
+		URL:       mangaURL,
+		MangaID:   mangaID,
+		Source:    source.Name(),
+		Mode:      mode,
+		State:     core.JobQueued,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// A worker may pick the job up as soon as it is sent, so it is saved
+	// and copied before
+	m.mu.Lock()
+	for _, other := range m.jobs {
+		if !other.Finished() && other.Source == job.Source && other.MangaID == job.MangaID {
+			active := *other
+			m.mu.Unlock()
+			return active, fmt.Errorf("%w: job %s", ErrJobActive, other.ID)
+		}
+	}
+	m.jobs[id] = job
+	m.events[id] = newEventStream()
+	snapshot := m.snapshot(*job)
+	queued := *job
+	m.mu.Unlock()
+	m.persist(snapshot)
+
+	select {
+	case m.queue <- id:
+	default:
+		m.mu.Lock()
+		delete(m.jobs, id)
+		delete(m.events, id)
+		m.mu.Unlock()
+		if m.store != nil {
+			if err := m.store.Delete(id); err != nil {
+				log.Printf("Failed to delete job %s: %v", id, err)
+			}
+		}
+		return core.Job{}, ErrQueueFull
+	}
+
+	log.Printf("Queued job %s for URL: %s", id, mangaURL)
+	return queued, nil
+}
+
+// ValidateURL checks that a registered source handles mangaURL and that
+// it is a series page.
+func (m *Manager) ValidateURL(mangaURL string) error {
+	source, err := m.sources.ForURL(mangaURL)
+	if err != nil {
+		return err
+	}
+	_, err = source.SeriesID(mangaURL)
+	return err
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (m *Manager) Get(id string) (core.Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return core.Job{}, false
+	}
+	return *job, true
+}
+
+// Subscribe returns the progress events of a job published so far and a
+// channel delivering the following ones until the job finishes. The
+// cancel function must be called once the caller stops reading.
+func (m *Manager) Subscribe(id string) ([]core.ProgressEvent, <-chan core.ProgressEvent, func(), bool) {
+	m.mu.RLock()
+	stream, ok := m.events[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	history, ch, cancel := stream.subscribe()
+	return history, ch, cancel, true
+}
+
+// Shutdown cancels running jobs and waits for the workers to exit or
+// for ctx to expire. The browser pool is closed once the workers are
+// done with their tabs.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		m.browsers.Close()
+		log.Println("Job manager stopped")
+		return nil
+	case <-ctx.Done():
+		go func() {
+			<-done
+			m.browsers.Close()
+		}()
+		return fmt.Errorf("job manager shutdown: %w", ctx.Err())
+	}
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case id := <-m.queue:
+			m.run(id)
+		}
+	}
+}
+
+func (m *Manager) run(id string) {
+	now := time.Now().UTC()
+	m.update(id, func(job *core.Job) {
+		job.State = core.JobRunning
+		job.StartedAt = &now
+	})
+
+	job, _ := m.Get(id)
+	err := m.execute(m.ctx, job)
+
+	// Jobs cut short by a shutdown stay unfinished and resume on restart
+	if err != nil && m.ctx.Err() != nil {
+		m.update(id, func(job *core.Job) {
+			job.State = core.JobQueued
+		})
+		m.closeEvents(id)
+		log.Printf("Job %s interrupted, it will resume on restart", id)
+		return
+	}
+
+	finished := time.Now().UTC()
+	m.update(id, func(job *core.Job) {
+		job.FinishedAt = &finished
+		if err != nil {
+			job.State = core.JobFailed
+			job.Error = err.Error()
+			return
+		}
+		job.State = core.JobSucceeded
+	})
+
+	final, _ := m.Get(id)
+	m.publish(id, core.ProgressEvent{
+		Type:           core.EventJobFinished,
+		ChaptersDone:   final.ChaptersDone,
+		ChaptersFailed: final.ChaptersFailed,
+		ChaptersTotal:  final.ChaptersTotal,
+		State:          final.State,
+		Error:          final.Error,
+		Time:           finished,
+	})
+	m.closeEvents(id)
+
+	if err != nil {
+		log.Printf("Job %s failed: %v", id, err)
+		return
+	}
+	log.Printf("Job %s finished successfully", id)
+}
+
+func (m *Manager) publish(id string, event core.ProgressEvent) {
+	m.mu.RLock()
+	stream, ok := m.events[id]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	event.JobID = id
+	stream.publish(event)
+}
+
+func (m *Manager) closeEvents(id string) {
+	m.mu.RLock()
+	stream, ok := m.events[id]
+	m.mu.RUnlock()
+	if ok {
+		stream.close()
+	}
+}
+
+func (m *Manager) update(id string, fn func(job *core.Job)) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	fn(job)
+	snapshot := m.snapshot(*job)
+	m.mu.Unlock()
+
+	m.persist(snapshot)
+}
+
+// track changes a job in memory only, the change is persisted with the
+// next update.
+func (m *Manager) track(id string, fn func(job *core.Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job, ok := m.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// jobSnapshot is the encoded state of a job waiting to be persisted.
+type jobSnapshot struct {
+	id   string
+	seq  uint64
+	data []byte
+}
+
+// snapshot encodes a job for persist. m.mu must be held.
+func (m *Manager) snapshot(job core.Job) jobSnapshot {
+	if m.store == nil {
+		return jobSnapshot{}
+	}
+	data, err := encodeJob(job)
+	if err != nil {
+		log.Printf("Failed to persist job %s: %v", job.ID, err)
+		return jobSnapshot{}
+	}
+	m.seq++
+	return jobSnapshot{id: job.ID, seq: m.seq, data: data}
+}
+
+// persist writes a snapshot without holding m.mu. Chapter workers update
+// a job concurrently, a snapshot older than the stored one is dropped.
+func (m *Manager) persist(snapshot jobSnapshot) {
+	if snapshot.data == nil {
+		return
+	}
+
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+
+	if snapshot.seq < m.written[snapshot.id] {
+		return
+	}
+	if err := m.store.write(snapshot.id, snapshot.data); err != nil {
+		log.Printf("Failed to persist job %s: %v", snapshot.id, err)
+		return
+	}
+	m.written[snapshot.id] = snapshot.seq
+}
+
+// execute performs the actual download for a job.
+func (m *Manager) execute(ctx context.Context, job core.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("PANIC RECOVERED in job %s: %v", job.ID, r)
+			debug.PrintStack()
+			err = fmt.Errorf("internal error: %v", r)
+		}
+	}()
+
+	config := client.Config{
+		BaseURL:        job.URL,
+		OutputFolder:   m.config.Downloader.OutputFolder,
+		UserAgent:      m.config.Downloader.UserAgent,
+		PageWorkers:    m.config.Downloader.PageWorkers,
+		ChapterWorkers: m.config.Downloader.ChapterWorkers,
+		Hosts:          m.hosts,
+		Limiter:        m.limiter,
+		Browsers:       m.browsers,
+		Blobs:          m.blobs,
+		RetryCount:     m.config.Download.RetryCount,
+		RetryDelay:     m.config.Download.DelayBetween,
+		BulkMaxDocs:    m.config.Elasticsearch.BulkMaxDocs,
+		BulkMaxBytes:   m.config.Elasticsearch.BulkMaxBytes,
+	}
+
+	source, ok := m.sources.Get(job.Source)
+	if !ok {
+		return fmt.Errorf("unknown source %q", job.Source)
+	}
+
+	log.Printf("Initializing %s downloader for job %s...", source.Name(), job.ID)
+	downloader, err := client.NewMangaDownloader(config, job.MangaID, source)
+	if err != nil {
+		return fmt.Errorf("failed to initialize downloader: %w", err)
+	}
+	defer downloader.Close()
+
+	downloader.SetElasticClient(m.elastic)
+	if job.Checkpoint != nil {
+		log.Printf("Job %s resuming after %d completed chapters", job.ID, len(job.Checkpoint.CompletedChapters))
+		downloader.Resume(*job.Checkpoint)
+	}
+	downloader.SetEventFunc(func(event core.ProgressEvent) {
+		progress := func(j *core.Job) {
+			j.ChaptersDone = event.ChaptersDone
+			j.ChaptersFailed = event.ChaptersFailed
+			j.ChaptersTotal = event.ChaptersTotal
+			updateCheckpoint(j, event)
+		}
+		// Page events are too frequent to rewrite the job file for, their
+		// progress is saved with the next chapter event
+		switch event.Type {
+		case core.EventPageDownloaded, core.EventPageFailed, core.EventPageIndexed:
+			m.track(job.ID, progress)
+		default:
+			m.update(job.ID, progress)
+		}
+		m.publish(job.ID, event)
+	})
+
+	// Get manga metadata
+	manga, err := downloader.FetchMetadata(ctx)
+	if err != nil {
+		log.Printf("Warning: Could not get manga metadata: %v", err)
+		manga.Title = "unknown"
+	}
+	manga.ID = job.MangaID
+	manga.Source = job.Source
+
+	// Create manga-specific index name
+	indexName := m.elastic.GetMangaIndexName(job.Source, job.MangaID)
+	if err := m.elastic.EnsureIndex(indexName); err != nil {
+		return fmt.Errorf("failed to ensure index exists: %w", err)
+	}
+	manga.IndexName = indexName
+	m.update(job.ID, func(j *core.Job) {
+		j.IndexName = indexName
+	})
+
+	if job.Mode == core.JobModeUpdate {
+		existing, err := m.elastic.GetIndexedChapters(indexName)
+		if err != nil {
+			return fmt.Errorf("failed to read indexed chapters: %w", err)
+		}
+		log.Printf("Job %s: %d chapters already indexed in %s", job.ID, len(existing), indexName)
+		downloader.SetSkipChapters(existing)
+	}
+
+	err = downloader.Run(ctx)
+	summary := downloader.Summary()
+	m.update(job.ID, func(j *core.Job) {
+		j.Summary = &summary
+	})
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	// Save manga metadata to repository
+	manga.Chapters = downloader.Chapters()
+	manga.UpdatedAt = time.Now().UTC()
+
+	if err := m.repository.SaveManga(manga); err != nil {
+		log.Printf("Warning: Failed to save manga metadata: %v", err)
+		// Continue anyway as we've already downloaded the images
+	}
+
+	return nil
+}
+
+// updateCheckpoint advances the checkpoint of a job with a progress event.
+// A resumed job restarts unfinished chapters from their first page.
+func updateCheckpoint(job *core.Job, event core.ProgressEvent) {
+	if job.Checkpoint == nil {
+		job.Checkpoint = &core.Checkpoint{CompletedChapters: []string{}}
+	}
+	if event.Type != core.EventChapterFinished {
+		return
+	}
+	job.Checkpoint.CompletedChapters = append(job.Checkpoint.CompletedChapters, event.Chapter)
+	job.Checkpoint.UpdatedAt = event.Time
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,254 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sucumbap/mangaroo/internal/core"
+	apperrors "github.com/sucumbap/mangaroo/pkg/errors"
+)
+
+// Scheduler runs an incremental update for every subscription whenever
+// its schedule is due.
+type Scheduler struct {
+	manager      ManagerInterface
+	repository   core.SubscriptionRepository
+	pollInterval time.Duration
+
+	mu      sync.Mutex
+	running map[string]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type SchedulerInterface interface {
+	Start()
+	Subscribe(mangaURL string, schedule string) (core.Subscription, error)
+	Stop(ctx context.Context) error
+}
+
+func NewScheduler(manager ManagerInterface, repository core.SubscriptionRepository, pollInterval time.Duration) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if pollInterval <= 0 {
+		pollInterval = time.Minute
+	}
+
+	return &Scheduler{
+		manager:      manager,
+		repository:   repository,
+		pollInterval: pollInterval,
+		running:      make(map[string]bool),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// ParseSchedule accepts standard five field cron expressions, descriptors
+// such as @daily or @every 6h, and plain durations like 6h.
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(spec); err == nil {
+		spec = "@every " + d.String()
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// Subscribe validates and stores a new subscription. Its first check
+// runs on the next poll.
+func (s *Scheduler) Subscribe(mangaURL string, schedule string) (core.Subscription, error) {
+	if _, err := ParseSchedule(schedule); err != nil {
+		return core.Subscription{}, err
+	}
+	if err := s.manager.ValidateURL(mangaURL); err != nil {
+		return core.Subscription{}, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return core.Subscription{}, fmt.Errorf("failed to generate subscription ID: %w", err)
+	}
+
+	now := time.Now().UTC()
+	subscription := core.Subscription{
+		ID:          id,
+		URL:         mangaURL,
+		Schedule:    strings.TrimSpace(schedule),
+		CreatedAt:   now,
+		NextCheckAt: now,
+	}
+
+	if err := s.repository.SaveSubscription(subscription); err != nil {
+		return core.Subscription{}, fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	log.Printf("Created subscription %s for %s (%s)", id, mangaURL, subscription.Schedule)
+	return subscription, nil
+}
+
+// Start launches the polling loop.
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		s.poll()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.poll()
+			}
+		}
+	}()
+	log.Printf("Subscription scheduler started, polling every %s", s.pollInterval)
+}
+
+// Stop ends the polling loop and waits for pending checks to be recorded
+// or for ctx to expire.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Subscription scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scheduler shutdown: %w", ctx.Err())
+	}
+}
+
+func (s *Scheduler) poll() {
+	subscriptions, err := s.repository.GetAllSubscriptions()
+	if err != nil {
+		log.Printf("Failed to load subscriptions: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, subscription := range subscriptions {
+		if subscription.NextCheckAt.After(now) || !s.claim(subscription.ID) {
+			continue
+		}
+		s.check(subscription)
+	}
+}
+
+// claim marks a subscription as being checked so overlapping polls don't
+// start a second job for it.
+func (s *Scheduler) claim(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[id] {
+		return false
+	}
+	s.running[id] = true
+	return true
+}
+
+func (s *Scheduler) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, id)
+}
+
+func (s *Scheduler) check(subscription core.Subscription) {
+	log.Printf("Checking subscription %s for %s", subscription.ID, subscription.URL)
+
+	job, err := s.manager.Submit(subscription.URL, core.JobModeUpdate)
+	if errors.Is(err, ErrJobActive) {
+		// The unfinished job picks up the new chapters, its outcome is
+		// recorded as the result of this check
+		log.Printf("Subscription %s follows job %s already downloading the series", subscription.ID, job.ID)
+	} else if err != nil {
+		s.record(subscription, core.Job{State: core.JobFailed, Error: err.Error()})
+		return
+	}
+
+	_, events, cancel, ok := s.manager.Subscribe(job.ID)
+	if !ok {
+		s.record(subscription, job)
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		// The stream is closed once the job finishes
+		for {
+			select {
+			case <-s.ctx.Done():
+				s.release(subscription.ID)
+				return
+			case _, open := <-events:
+				if open {
+					continue
+				}
+				final, _ := s.manager.Get(job.ID)
+				s.record(subscription, final)
+				return
+			}
+		}
+	}()
+}
+
+// record stores the outcome of a check and schedules the next one.
+func (s *Scheduler) record(subscription core.Subscription, job core.Job) {
+	defer s.release(subscription.ID)
+
+	// Don't bring back a subscription deleted while its check was running
+	if _, err := s.repository.GetSubscriptionByID(subscription.ID); err != nil {
+		if apperrors.IsNotFound(err) {
+			return
+		}
+		log.Printf("Failed to reload subscription %s: %v", subscription.ID, err)
+	}
+
+	now := time.Now().UTC()
+	subscription.LastCheckedAt = &now
+	subscription.LastJobID = job.ID
+	subscription.LastState = job.State
+	subscription.LastError = job.Error
+	subscription.LastSummary = job.Summary
+
+	schedule, err := ParseSchedule(subscription.Schedule)
+	if err != nil {
+		log.Printf("Subscription %s has an invalid schedule: %v", subscription.ID, err)
+		subscription.LastError = err.Error()
+		subscription.NextCheckAt = now.Add(24 * time.Hour)
+	} else {
+		subscription.NextCheckAt = schedule.Next(now)
+	}
+
+	if err := s.repository.SaveSubscription(subscription); err != nil {
+		log.Printf("Failed to save subscription %s: %v", subscription.ID, err)
+		return
+	}
+	log.Printf("Subscription %s checked (%s), next check at %s", subscription.ID, job.State, subscription.NextCheckAt.Format(time.RFC3339))
+}
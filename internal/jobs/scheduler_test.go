@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sucumbap/mangaroo/internal/core"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	for spec, want := range map[string]time.Time{
+		"0 6 * * *": time.Date(2024, 3, 11, 6, 0, 0, 0, time.UTC),
+		"@daily":    time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+		"@every 6h": from.Add(6 * time.Hour),
+		"90m":       from.Add(90 * time.Minute),
+		"  12h ":    from.Add(12 * time.Hour),
+	} {
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(want) {
+			t.Errorf("ParseSchedule(%q).Next = %v, want %v", spec, got, want)
+		}
+	}
+
+	// Six field expressions with seconds are not accepted
+	for _, spec := range []string{"", "often", "0 6 * *", "0 0 6 * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// activeManager reports an unfinished job for every submission, like a
+// series that is still downloading when its subscription is due.
+type activeManager struct {
+	ManagerInterface
+	job    core.Job
+	events chan core.ProgressEvent
+}
+
+func (m *activeManager) Submit(string, core.JobMode) (core.Job, error) {
+	return m.job, fmt.Errorf("%w: job %s", ErrJobActive, m.job.ID)
+}
+
+func (m *activeManager) Subscribe(string) ([]core.ProgressEvent, <-chan core.ProgressEvent, func(), bool) {
+	return nil, m.events, func() {}, true
+}
+
+func (m *activeManager) Get(string) (core.Job, bool) {
+	return m.job, true
+}
+
+type memorySubscriptions struct {
+	core.SubscriptionRepository
+	mu            sync.Mutex
+	subscriptions map[string]core.Subscription
+}
+
+func (r *memorySubscriptions) SaveSubscription(subscription core.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscriptions[subscription.ID] = subscription
+	return nil
+}
+
+func (r *memorySubscriptions) GetSubscriptionByID(id string) (core.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.subscriptions[id], nil
+}
+
+func (r *memorySubscriptions) GetAllSubscriptions() ([]core.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []core.Subscription
+	for _, subscription := range r.subscriptions {
+		all = append(all, subscription)
+	}
+	return all, nil
+}
+
+func TestSchedulerFollowsActiveJob(t *testing.T) {
+	manager := &activeManager{
+		job:    core.Job{ID: "running", State: core.JobRunning},
+		events: make(chan core.ProgressEvent),
+	}
+	due := time.Now().UTC().Add(-time.Minute)
+	repository := &memorySubscriptions{subscriptions: map[string]core.Subscription{
+		"sub": {ID: "sub", URL: "https://example.com/manga/foo", Schedule: "1h", NextCheckAt: due},
+	}}
+	s := NewScheduler(manager, repository, time.Hour)
+
+	s.poll()
+	if s.claim("sub") {
+		t.Fatal("subscription was not claimed while its job runs")
+	}
+
+	manager.job.State = core.JobSucceeded
+	close(manager.events)
+	s.wg.Wait()
+
+	subscription, _ := repository.GetSubscriptionByID("sub")
+	if subscription.LastJobID != "running" || subscription.LastState != core.JobSucceeded {
+		t.Errorf("recorded job %q in state %q, want the running job's outcome", subscription.LastJobID, subscription.LastState)
+	}
+	if !subscription.NextCheckAt.After(time.Now()) {
+		t.Errorf("NextCheckAt = %v, want the next scheduled check", subscription.NextCheckAt)
+	}
+	if !s.claim("sub") {
+		t.Error("subscription is still claimed after its check was recorded")
+	}
+}
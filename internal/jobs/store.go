@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sucumbap/mangaroo/internal/core"
+)
+
+// Store persists jobs and their checkpoints as one JSON file per job so
+// unfinished work survives a restart.
+type Store struct {
+	dir string
+}
+
+type StoreInterface interface {
+	Save(job core.Job) error
+	LoadAll() ([]core.Job, error)
+	Delete(id string) error
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating job state folder: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save writes the job atomically so a crash never leaves a torn file.
+func (s *Store) Save(job core.Job) error {
+	data, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	return s.write(job.ID, data)
+}
+
+func encodeJob(job core.Job) ([]byte, error) {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job: %w", err)
+	}
+	return data, nil
+}
+
+// write replaces the state of job id with data encoded by encodeJob.
+func (s *Store) write(id string, data []byte) error {
+	tempPath := s.path(id) + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write job state: %w", err)
+	}
+	if err := os.Rename(tempPath, s.path(id)); err != nil {
+		return fmt.Errorf("failed to replace job state: %w", err)
+	}
+	return nil
+}
+
+// LoadAll returns every stored job. Unreadable files are skipped.
+func (s *Store) LoadAll() ([]core.Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job state: %w", err)
+	}
+
+	var jobs []core.Job
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			log.Printf("Failed to read job state %s: %v", entry.Name(), err)
+			continue
+		}
+
+		var job core.Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			log.Printf("Failed to parse job state %s: %v", entry.Name(), err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (s *Store) Delete(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete job state: %w", err)
+	}
+	return nil
+}
@@ -16,11 +16,75 @@ type Config struct {
 
 	Elasticsearch struct {
 		URL string `envconfig:"ELASTICSEARCH_URL" default:"http://elasticsearch:9200"`
+		// BulkMaxDocs and BulkMaxBytes bound a single _bulk request, pages
+		// are flushed earlier at the end of each chapter
+		BulkMaxDocs  int `envconfig:"ES_BULK_MAX_DOCS" default:"500"`
+		BulkMaxBytes int `envconfig:"ES_BULK_MAX_BYTES" default:"5242880"`
+		// MigrateOnStartup migrates indices behind their template's schema
+		// version in the background when the server starts. Without it run
+		// "mangaroo migrate" after upgrading, catalog documents are only
+		// re-keyed by source by the migration
+		MigrateOnStartup bool `envconfig:"ES_MIGRATE_ON_STARTUP" default:"true"`
+		// KeepOldIndices keeps the previous index of a migrated alias
+		KeepOldIndices bool `envconfig:"ES_MIGRATE_KEEP_OLD" default:"false"`
 	}
 
 	Downloader struct {
 		OutputFolder string `envconfig:"OUTPUT_FOLDER" default:"output"`
 		UserAgent    string `envconfig:"USER_AGENT" default:"Mozilla/5.0..."`
+		SitesDir     string `envconfig:"SITES_DIR" default:"configs/sites"`
+		// PageWorkers is the number of images fetched at once per chapter
+		PageWorkers int `envconfig:"DOWNLOAD_PAGE_WORKERS" default:"4"`
+		// ChapterWorkers is the number of chapters of a job downloaded at once
+		ChapterWorkers int `envconfig:"DOWNLOAD_CHAPTER_WORKERS" default:"1"`
+		// HostConcurrency caps concurrent image requests per host across all jobs
+		HostConcurrency int `envconfig:"DOWNLOAD_HOST_CONCURRENCY" default:"4"`
+	}
+
+	Download DownloadConfig
+
+	Browser BrowserConfig
+
+	// Storage holds the page images referenced by the index
+	Storage struct {
+		Backend string `envconfig:"STORAGE_BACKEND" default:"local"`
+		// Dir is the local blob folder, defaults to <OutputFolder>/blobs
+		Dir string `envconfig:"STORAGE_DIR"`
+
+		S3 struct {
+			Endpoint  string `envconfig:"STORAGE_S3_ENDPOINT"`
+			Region    string `envconfig:"STORAGE_S3_REGION"`
+			Bucket    string `envconfig:"STORAGE_S3_BUCKET" default:"mangaroo"`
+			Prefix    string `envconfig:"STORAGE_S3_PREFIX"`
+			AccessKey string `envconfig:"STORAGE_S3_ACCESS_KEY"`
+			SecretKey string `envconfig:"STORAGE_S3_SECRET_KEY"`
+			UseSSL    bool   `envconfig:"STORAGE_S3_USE_SSL" default:"true"`
+			// PartSize is the multipart chunk size in bytes, at least 5MiB
+			PartSize uint64 `envconfig:"STORAGE_S3_PART_SIZE" default:"16777216"`
+			// PublicEndpoint is the URL clients reach the bucket at, blobs
+			// are served through the API without it
+			PublicEndpoint string        `envconfig:"STORAGE_S3_PUBLIC_ENDPOINT"`
+			PresignExpiry  time.Duration `envconfig:"STORAGE_S3_PRESIGN_EXPIRY" default:"15m"`
+		}
+	}
+
+	// RateLimit applies per host to image requests and browser navigations
+	// of every job combined
+	RateLimit struct {
+		RequestsPerSecond float64 `envconfig:"RATE_LIMIT_RPS" default:"2"`
+		Burst             int     `envconfig:"RATE_LIMIT_BURST" default:"2"`
+	}
+
+	Scheduler struct {
+		PollInterval time.Duration `envconfig:"SCHEDULER_POLL_INTERVAL" default:"1m"`
+	}
+
+	Jobs struct {
+		Workers   int `envconfig:"JOB_WORKERS" default:"2"`
+		QueueSize int `envconfig:"JOB_QUEUE_SIZE" default:"100"`
+		// StateDir holds job checkpoints, defaults to <OutputFolder>/.jobs
+		StateDir  string        `envconfig:"JOB_STATE_DIR"`
+		Retention time.Duration `envconfig:"JOB_RETENTION" default:"168h"`
 	}
 }
 
@@ -29,6 +93,16 @@ type BrowserConfig struct {
 	Headless    bool          `envconfig:"BROWSER_HEADLESS" default:"true"`
 	Timeout     time.Duration `envconfig:"BROWSER_TIMEOUT" default:"30s"`
 	UserDataDir string        `envconfig:"BROWSER_USER_DATA_DIR"`
+	// Instances is the number of Chrome processes kept running
+	Instances int `envconfig:"BROWSER_INSTANCES" default:"1"`
+	// Tabs is the number of tabs kept open per instance
+	Tabs int `envconfig:"BROWSER_TABS" default:"2"`
+	// LeaseTimeout bounds how long a download waits for a free tab
+	LeaseTimeout time.Duration `envconfig:"BROWSER_LEASE_TIMEOUT" default:"2m"`
+	// MaxLease reclaims tabs held longer than this
+	MaxLease time.Duration `envconfig:"BROWSER_MAX_LEASE" default:"10m"`
+	// MaxUses recycles a tab after this many leases
+	MaxUses int `envconfig:"BROWSER_TAB_MAX_USES" default:"50"`
 }
 
 type DownloadConfig struct {